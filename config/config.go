@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/go-sphere/confstore"
 	"github.com/go-sphere/confstore/codec"
@@ -13,8 +14,21 @@ import (
 type BackupProviderConfigType string
 
 const (
-	BackupProviderConfigTypeGitea BackupProviderConfigType = "gitea"
-	BackupProviderConfigTypeLocal BackupProviderConfigType = "local"
+	BackupProviderConfigTypeGitea  BackupProviderConfigType = "gitea"
+	BackupProviderConfigTypeLocal  BackupProviderConfigType = "local"
+	BackupProviderConfigTypeBundle BackupProviderConfigType = "bundle"
+	BackupProviderConfigTypeS3     BackupProviderConfigType = "s3"
+	BackupProviderConfigTypeGitlab BackupProviderConfigType = "gitlab"
+	BackupProviderConfigTypePush   BackupProviderConfigType = "push"
+)
+
+type SourceProviderConfigType string
+
+const (
+	SourceProviderConfigTypeGithub    SourceProviderConfigType = "github"
+	SourceProviderConfigTypeGitlab    SourceProviderConfigType = "gitlab"
+	SourceProviderConfigTypeGitea     SourceProviderConfigType = "gitea"
+	SourceProviderConfigTypeBitbucket SourceProviderConfigType = "bitbucket"
 )
 
 type UnmatchedRepoAction string
@@ -25,27 +39,62 @@ const (
 )
 
 type BackupProviderConfig struct {
-	Type   BackupProviderConfigType `json:"type"`
+	Name        string                   `json:"name,omitempty"`
+	Type        BackupProviderConfigType `json:"type"`
+	Config      json.RawMessage          `json:"config"`
+	Filter      *FilterConfig            `json:"filter,omitempty"`
+	Concurrency int                      `json:"concurrency,omitempty"`
+}
+
+type SourceProviderConfig struct {
+	Type   SourceProviderConfigType `json:"type"`
 	Config json.RawMessage          `json:"config"`
 }
 
 type DefaultConfig struct {
-	GithubToken         string                `json:"github_token"`
-	RepoOwner           string                `json:"repo_owner"`
-	Backup              *BackupProviderConfig `json:"backup"`
-	Filter              *FilterConfig         `json:"filter"`
-	SpecificGithubToken map[string]string     `json:"specific_github_token"`
+	GithubToken         string                  `json:"github_token"`
+	RepoOwner           string                  `json:"repo_owner"`
+	Source              *SourceProviderConfig   `json:"source"`
+	Backup              *BackupProviderConfig   `json:"backup"`
+	Backups             []*BackupProviderConfig `json:"backups,omitempty"`
+	Filter              *FilterConfig           `json:"filter"`
+	Include             *IncludeConfig          `json:"include"`
+	Retry               *RetryConfig            `json:"retry,omitempty"`
+	SpecificGithubToken map[string]string       `json:"specific_github_token"`
 }
 
 type GithubConfig struct {
-	Owner               string                `json:"owner"`
-	Token               string                `json:"token"`
-	IsOwnerOrg          bool                  `json:"is_owner_org"`
-	RepoOwner           string                `json:"repo_owner"`
-	IsRepoOwnerOrg      bool                  `json:"is_repo_owner_org"`
-	Backup              *BackupProviderConfig `json:"backup"`
-	Filter              *FilterConfig         `json:"filter"`
-	SpecificGithubToken map[string]string     `json:"specific_github_token"`
+	Owner               string                  `json:"owner"`
+	Token               string                  `json:"token"`
+	IsOwnerOrg          bool                    `json:"is_owner_org"`
+	RepoOwner           string                  `json:"repo_owner"`
+	IsRepoOwnerOrg      bool                    `json:"is_repo_owner_org"`
+	Source              *SourceProviderConfig   `json:"source"`
+	Backup              *BackupProviderConfig   `json:"backup"`
+	Backups             []*BackupProviderConfig `json:"backups,omitempty"`
+	Filter              *FilterConfig           `json:"filter"`
+	Include             *IncludeConfig          `json:"include"`
+	Retry               *RetryConfig            `json:"retry,omitempty"`
+	SpecificGithubToken map[string]string       `json:"specific_github_token"`
+}
+
+type IncludeConfig struct {
+	Wiki         bool `json:"wiki"`
+	Issues       bool `json:"issues"`
+	PullRequests bool `json:"pull_requests"`
+	Labels       bool `json:"labels"`
+	Milestones   bool `json:"milestones"`
+	Releases     bool `json:"releases"`
+	LFS          bool `json:"lfs"`
+}
+
+type RetryConfig struct {
+	MaxAttempts      int      `json:"max_attempts"`
+	InitialBackoff   string   `json:"initial_backoff"`
+	MaxBackoff       string   `json:"max_backoff"`
+	Jitter           *float64 `json:"jitter"`
+	StatusCodes      []int    `json:"status_codes"`
+	GitErrorPatterns []string `json:"git_error_patterns"`
 }
 
 type FilterConfig struct {
@@ -56,8 +105,13 @@ type FilterConfig struct {
 }
 
 func (c *GithubConfig) MergeDefault(defaultConf *DefaultConfig) {
+	// targets still get an owner, a filter and include options without default_conf
 	if defaultConf == nil {
-		return
+		defaultConf = &DefaultConfig{}
+	}
+	defaultFilter := defaultConf.Filter
+	if defaultFilter == nil {
+		defaultFilter = &FilterConfig{}
 	}
 	if c.Token == "" {
 		c.Token = defaultConf.GithubToken
@@ -68,37 +122,113 @@ func (c *GithubConfig) MergeDefault(defaultConf *DefaultConfig) {
 	if c.RepoOwner == "" {
 		c.RepoOwner = c.Owner
 	}
-	if c.Backup == nil {
+	if c.Source == nil {
+		c.Source = defaultConf.Source
+	}
+	if c.Backup == nil && len(c.Backups) == 0 {
 		c.Backup = defaultConf.Backup
+		c.Backups = defaultConf.Backups
 	}
 	if c.Filter == nil {
-		c.Filter = defaultConf.Filter
-		if c.Filter == nil {
-			c.Filter = &FilterConfig{}
-		}
+		// every target gets its own copy, the defaults below must not leak into other targets
+		filter := *defaultFilter
+		c.Filter = &filter
 	}
 	if c.Filter.UnmatchedRepoAction == "" {
-		c.Filter.UnmatchedRepoAction = defaultConf.Filter.UnmatchedRepoAction
-		c.Filter.PreDeleteCheckCount = defaultConf.Filter.PreDeleteCheckCount
+		c.Filter.UnmatchedRepoAction = defaultFilter.UnmatchedRepoAction
+		c.Filter.PreDeleteCheckCount = defaultFilter.PreDeleteCheckCount
 		if c.Filter.UnmatchedRepoAction == "" {
 			c.Filter.UnmatchedRepoAction = UnmatchedRepoActionIgnore
 		}
 	}
 	if len(c.Filter.AllowRule) == 0 {
-		c.Filter.AllowRule = defaultConf.Filter.AllowRule
+		c.Filter.AllowRule = defaultFilter.AllowRule
 	}
 	if len(c.Filter.DenyRule) == 0 {
-		c.Filter.DenyRule = defaultConf.Filter.DenyRule
+		c.Filter.DenyRule = defaultFilter.DenyRule
+	}
+	if c.Include == nil {
+		c.Include = defaultConf.Include
+		if c.Include == nil {
+			c.Include = &IncludeConfig{}
+		}
+	}
+	if c.Retry == nil {
+		c.Retry = defaultConf.Retry
 	}
 	if len(c.SpecificGithubToken) == 0 {
 		c.SpecificGithubToken = defaultConf.SpecificGithubToken
 	}
 }
 
+// DestinationFilter returns the filter of dest merged over the target filter, destinations from default_conf are
+// shared by every target so the merged filter is never stored in dest.
+func (c *GithubConfig) DestinationFilter(dest *BackupProviderConfig) *FilterConfig {
+	if dest.Filter == nil {
+		return c.Filter
+	}
+	return c.Filter.Merge(dest.Filter)
+}
+
+// Merge returns a copy of c with every field that is set in override replaced.
+func (c *FilterConfig) Merge(override *FilterConfig) *FilterConfig {
+	merged := *c
+	if override.UnmatchedRepoAction != "" {
+		merged.UnmatchedRepoAction = override.UnmatchedRepoAction
+	}
+	if override.PreDeleteCheckCount > 0 {
+		merged.PreDeleteCheckCount = override.PreDeleteCheckCount
+	}
+	if len(override.AllowRule) > 0 {
+		merged.AllowRule = override.AllowRule
+	}
+	if len(override.DenyRule) > 0 {
+		merged.DenyRule = override.DenyRule
+	}
+	return &merged
+}
+
+// Destinations returns every backup provider of the target, backup comes first followed by backups.
+func (c *GithubConfig) Destinations() []*BackupProviderConfig {
+	destinations := make([]*BackupProviderConfig, 0, len(c.Backups)+1)
+	if c.Backup != nil {
+		destinations = append(destinations, c.Backup)
+	}
+	return append(destinations, c.Backups...)
+}
+
+// DestinationNames returns the name of every destination in the order of Destinations. Names key the sync state,
+// so they are required once a target has more than one destination, a single destination defaults to type#0.
+func (c *GithubConfig) DestinationNames() ([]string, error) {
+	destinations := c.Destinations()
+	names := make([]string, 0, len(destinations))
+	seen := make(map[string]struct{}, len(destinations))
+	for i, dest := range destinations {
+		name := dest.Name
+		if name == "" {
+			if len(destinations) > 1 {
+				return nil, fmt.Errorf("target %s has %d backup destinations, every destination needs a name", c.Owner, len(destinations))
+			}
+			name = fmt.Sprintf("%s#%d", dest.Type, i)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("target %s has more than one backup destination named %s", c.Owner, name)
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
 type SyncConfig struct {
-	DefaultConf *DefaultConfig  `json:"default_conf"`
-	Targets     []*GithubConfig `json:"targets"`
-	Cron        string          `json:"cron"`
+	DefaultConf         *DefaultConfig  `json:"default_conf"`
+	Targets             []*GithubConfig `json:"targets"`
+	Cron                string          `json:"cron"`
+	Concurrency         int             `json:"concurrency"`
+	StateFile           string          `json:"state_file"`
+	Incremental         bool            `json:"incremental"`
+	FullRefreshInterval string          `json:"full_refresh_interval"`
+	RateLimitReserve    int             `json:"rate_limit_reserve"`
 }
 
 func Convert[T any](raw json.RawMessage) (*T, error) {
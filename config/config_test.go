@@ -57,3 +57,90 @@ func TestSyncConfig(t *testing.T) {
 	}
 	fmt.Printf("%+v\n", c)
 }
+
+func TestGithubConfig_Destinations(t *testing.T) {
+	defaultConf := &DefaultConfig{
+		Backup: &BackupProviderConfig{Type: BackupProviderConfigTypeGitea},
+		Filter: &FilterConfig{UnmatchedRepoAction: UnmatchedRepoActionDelete},
+	}
+	target := &GithubConfig{
+		Owner: "GITHUB_OWNER",
+		Backups: []*BackupProviderConfig{
+			{Name: "mirror", Type: BackupProviderConfigTypeLocal},
+			{Name: "archive", Type: BackupProviderConfigTypeBundle, Filter: &FilterConfig{DenyRule: []string{"archived"}}},
+		},
+		Filter: &FilterConfig{AllowRule: []string{"public"}},
+	}
+	target.MergeDefault(defaultConf)
+	destinations := target.Destinations()
+	if len(destinations) != 2 || destinations[0].Name != "mirror" || destinations[1].Name != "archive" {
+		t.Fatalf("unexpected destinations %+v", destinations)
+	}
+	filter := target.DestinationFilter(destinations[1])
+	if filter.UnmatchedRepoAction != UnmatchedRepoActionDelete || len(filter.AllowRule) != 1 || len(filter.DenyRule) != 1 {
+		t.Errorf("destination filter should be merged over the target filter, got %+v", filter)
+	}
+	if len(target.Filter.DenyRule) != 0 {
+		t.Errorf("target filter should not be changed, got %+v", target.Filter)
+	}
+	names, err := target.DestinationNames()
+	if err != nil || len(names) != 2 || names[1] != "archive" {
+		t.Fatalf("unexpected destination names %v %v", names, err)
+	}
+	target.Backups[0].Name = ""
+	if _, err = target.DestinationNames(); err == nil {
+		t.Errorf("unnamed destinations should be rejected when there is more than one")
+	}
+
+	target = &GithubConfig{Owner: "GITHUB_OWNER"}
+	target.MergeDefault(defaultConf)
+	destinations = target.Destinations()
+	if len(destinations) != 1 || destinations[0].Type != BackupProviderConfigTypeGitea {
+		t.Fatalf("unexpected default destinations %+v", destinations)
+	}
+	if names, _ = target.DestinationNames(); len(names) != 1 || names[0] != "gitea#0" {
+		t.Errorf("unexpected default destination names %v", names)
+	}
+}
+
+func TestGithubConfig_MergeNilDefault(t *testing.T) {
+	target := &GithubConfig{
+		Owner:  "GITHUB_OWNER",
+		Backup: &BackupProviderConfig{Type: BackupProviderConfigTypeLocal},
+		Filter: &FilterConfig{DenyRule: []string{"archived"}},
+	}
+	target.MergeDefault(nil)
+	if target.RepoOwner != "GITHUB_OWNER" {
+		t.Errorf("repo owner should default to the owner, got %q", target.RepoOwner)
+	}
+	if target.Include == nil {
+		t.Fatal("include should default to an empty config")
+	}
+	if target.Filter.UnmatchedRepoAction != UnmatchedRepoActionIgnore || len(target.Filter.DenyRule) != 1 {
+		t.Errorf("unexpected filter %+v", target.Filter)
+	}
+}
+
+func TestGithubConfig_SharedDestinations(t *testing.T) {
+	defaultConf := &DefaultConfig{
+		Backups: []*BackupProviderConfig{
+			{Name: "mirror", Type: BackupProviderConfigTypeLocal, Filter: &FilterConfig{DenyRule: []string{"x"}}},
+		},
+	}
+	a := &GithubConfig{Owner: "a", Filter: &FilterConfig{AllowRule: []string{"onlyA"}}}
+	b := &GithubConfig{Owner: "b"}
+	// cron runs merge the defaults again on every run
+	for range 2 {
+		a.MergeDefault(defaultConf)
+		b.MergeDefault(defaultConf)
+	}
+	if filter := a.DestinationFilter(a.Destinations()[0]); len(filter.AllowRule) != 1 || filter.AllowRule[0] != "onlyA" || len(filter.DenyRule) != 1 {
+		t.Errorf("unexpected filter of a %+v", filter)
+	}
+	if filter := b.DestinationFilter(b.Destinations()[0]); len(filter.AllowRule) != 0 || len(filter.DenyRule) != 1 {
+		t.Errorf("filter of a leaked into b %+v", filter)
+	}
+	if shared := defaultConf.Backups[0].Filter; len(shared.AllowRule) != 0 || shared.UnmatchedRepoAction != "" {
+		t.Errorf("shared destination filter was changed %+v", shared)
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
 
-	"github.com/TBXark/github-backup/config"
 	"github.com/robfig/cron/v3"
 )
 
@@ -14,9 +14,20 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "restore":
+			runRestore(os.Args[2:])
+			return
+		case "verify":
+			runVerify(os.Args[2:])
+			return
+		}
+	}
 	conf := flag.String("config", "config.json", "config file")
 	version := flag.Bool("version", false, "show version")
 	help := flag.Bool("help", false, "show help")
+	dryRun := flag.Bool("dry-run", false, "print the repos that would be created, updated, skipped or deleted without changing any backup")
 	flag.Parse()
 	if *version {
 		fmt.Println(BuildVersion)
@@ -26,20 +37,20 @@ func main() {
 		flag.Usage()
 		return
 	}
-	data, err := config.NewConfig(*conf)
+	data, err := LoadConfig(*conf)
 	if err != nil {
 		log.Fatalf("load config error: %s", err.Error())
 	}
 
-	syncTask := NewTask(data)
-	if data.Cron != "" {
+	syncTask := NewTask(data, *dryRun)
+	if data.Cron != "" && !*dryRun {
 		task := cron.New()
 		_, e := task.AddJob(data.Cron, syncTask)
 		if e != nil {
 			log.Fatalf("add cron task error: %s", e.Error())
 		}
 		task.Run()
-	} else {
-		syncTask.Run()
+	} else if err = syncTask.RunOnce(); err != nil {
+		log.Fatalf("%s", err.Error())
 	}
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/TBXark/github-backup/config"
+	"github.com/TBXark/github-backup/provider/provider"
+)
+
+func metadataKinds(include *config.IncludeConfig) []provider.MetadataKind {
+	kinds := make([]provider.MetadataKind, 0)
+	if include.Issues {
+		kinds = append(kinds, provider.MetadataKindIssues)
+	}
+	// pull request conversations are stored as issue comments
+	if include.Issues || include.PullRequests {
+		kinds = append(kinds, provider.MetadataKindIssueComments)
+	}
+	if include.PullRequests {
+		kinds = append(kinds, provider.MetadataKindPullRequests, provider.MetadataKindReviewComments)
+	}
+	if include.Labels {
+		kinds = append(kinds, provider.MetadataKindLabels)
+	}
+	if include.Milestones {
+		kinds = append(kinds, provider.MetadataKindMilestones)
+	}
+	return kinds
+}
+
+func exportMetadata(source provider.Source, backup provider.Provider, to *provider.Owner, repo *provider.SourceRepo, token string, kinds []provider.MetadataKind) error {
+	exporter, ok := source.(provider.MetadataSource)
+	if !ok {
+		return nil
+	}
+	store, ok := backup.(provider.MetadataStore)
+	if !ok {
+		return nil
+	}
+	for _, kind := range kinds {
+		metadata, err := store.LoadMetadata(to, repo.Name, kind)
+		if err != nil {
+			return err
+		}
+		since := time.Now()
+		items, err := exporter.ExportMetadata(repo, token, kind, metadata.Since)
+		if err != nil {
+			return err
+		}
+		// labels and milestones are always exported in full, replace them so deletions are kept in sync
+		if kind == provider.MetadataKindLabels || kind == provider.MetadataKindMilestones {
+			metadata.Items = nil
+		}
+		err = metadata.Merge(items)
+		if err != nil {
+			return err
+		}
+		metadata.Since = since
+		err = store.SaveMetadata(to, repo.Name, metadata)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func archiveReleases(source provider.Source, backup provider.Provider, to *provider.Owner, repo *provider.SourceRepo, token string) error {
+	releaseSource, ok := source.(provider.ReleaseSource)
+	if !ok {
+		return nil
+	}
+	store, ok := backup.(provider.ReleaseStore)
+	if !ok {
+		return nil
+	}
+	releases, err := releaseSource.ListReleases(repo, token)
+	if err != nil {
+		return err
+	}
+	download := func(asset *provider.ReleaseAsset) (io.ReadCloser, error) {
+		return releaseSource.DownloadAsset(asset, token)
+	}
+	for _, release := range releases {
+		err = store.SaveRelease(to, repo.Name, release, download)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveRepoInfo(backup provider.Provider, to *provider.Owner, repo *provider.SourceRepo) error {
+	store, ok := backup.(provider.RepoInfoStore)
+	if !ok {
+		return nil
+	}
+	return store.SaveRepoInfo(to, &provider.RepoInfo{
+		Name:        repo.Name,
+		Description: repo.Description,
+		Private:     repo.Private,
+		Topics:      repo.Topics,
+	})
+}
@@ -0,0 +1,176 @@
+package bitbucket
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/TBXark/github-backup/provider/provider"
+	"github.com/TBXark/github-backup/utils/request"
+)
+
+type Config struct {
+	Host     string `json:"host"`
+	Server   bool   `json:"server"`
+	Username string `json:"username"`
+	Token    string `json:"token"`
+}
+
+var _ provider.Source = &Bitbucket{}
+
+type Bitbucket struct {
+	conf *Config
+}
+
+func NewBitbucket(conf *Config) *Bitbucket {
+	conf.Host = strings.TrimRight(conf.Host, "/")
+	if conf.Server {
+		if !strings.HasSuffix(conf.Host, "/rest/api/1.0") {
+			conf.Host += "/rest/api/1.0"
+		}
+	} else if conf.Host == "" {
+		conf.Host = "https://api.bitbucket.org/2.0"
+	}
+	return &Bitbucket{conf: conf}
+}
+
+func (b *Bitbucket) requestModifier() []request.Modifier {
+	if b.conf.Username != "" {
+		return []request.Modifier{
+			request.WithBasicAuth(b.conf.Username, b.conf.Token),
+		}
+	}
+	return []request.Modifier{
+		request.WithAuthorization(b.conf.Token, "Bearer"),
+	}
+}
+
+func (b *Bitbucket) Service() string {
+	return "git"
+}
+
+func (b *Bitbucket) Credentials(token string) (string, string) {
+	return b.conf.Username, token
+}
+
+func (b *Bitbucket) ListRepos(owner *provider.Owner) ([]*provider.SourceRepo, error) {
+	if b.conf.Server {
+		return b.listServerRepos(owner)
+	}
+	return b.listCloudRepos(owner)
+}
+
+func (b *Bitbucket) listCloudRepos(owner *provider.Owner) ([]*provider.SourceRepo, error) {
+	repos := make([]*provider.SourceRepo, 0)
+	next := fmt.Sprintf("%s/repositories/%s?pagelen=100", b.conf.Host, url.PathEscape(owner.Name))
+	for next != "" {
+		res, err := request.GET[cloudReposQuery](next, b.requestModifier()...)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range res.Values {
+			repos = append(repos, &provider.SourceRepo{
+				Name:        r.Slug,
+				Description: r.Description,
+				Private:     r.IsPrivate,
+				Fork:        r.Parent != nil,
+				CloneURL:    cloneLink(r.Links.Clone, "https"),
+				SSHURL:      cloneLink(r.Links.Clone, "ssh"),
+			})
+		}
+		next = res.Next
+	}
+	return repos, nil
+}
+
+func (b *Bitbucket) buildServerReposPath(owner string, isOrg bool) string {
+	if isOrg {
+		return fmt.Sprintf("projects/%s/repos", url.PathEscape(owner))
+	} else {
+		return fmt.Sprintf("users/%s/repos", url.PathEscape(owner))
+	}
+}
+
+func (b *Bitbucket) listServerRepos(owner *provider.Owner) ([]*provider.SourceRepo, error) {
+	limit := 100
+	start := 0
+	repos := make([]*provider.SourceRepo, 0)
+	for {
+		url := fmt.Sprintf("%s/%s?limit=%d&start=%d", b.conf.Host, b.buildServerReposPath(owner.Name, owner.IsOrg), limit, start)
+		res, err := request.GET[serverReposQuery](url, b.requestModifier()...)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range res.Values {
+			repos = append(repos, &provider.SourceRepo{
+				Name:        r.Slug,
+				Description: r.Description,
+				Private:     !r.Public,
+				Fork:        r.Origin != nil,
+				Archived:    r.Archived,
+				CloneURL:    cloneLink(r.Links.Clone, "http"),
+				SSHURL:      cloneLink(r.Links.Clone, "ssh"),
+			})
+		}
+		if res.IsLastPage {
+			break
+		}
+		start = res.NextPageStart
+	}
+	return repos, nil
+}
+
+func cloneLink(links []link, name string) string {
+	for _, l := range links {
+		if l.Name != name {
+			continue
+		}
+		if name == "ssh" {
+			return l.Href
+		}
+		u, err := url.Parse(l.Href)
+		if err != nil {
+			return l.Href
+		}
+		u.User = nil
+		return u.String()
+	}
+	return ""
+}
+
+type link struct {
+	Name string `json:"name"`
+	Href string `json:"href"`
+}
+
+type cloudReposQuery struct {
+	Next   string `json:"next"`
+	Values []struct {
+		Slug        string `json:"slug"`
+		Description string `json:"description"`
+		IsPrivate   bool   `json:"is_private"`
+		Parent      *struct {
+			FullName string `json:"full_name"`
+		} `json:"parent"`
+		Links struct {
+			Clone []link `json:"clone"`
+		} `json:"links"`
+	} `json:"values"`
+}
+
+type serverReposQuery struct {
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+	Values        []struct {
+		Slug        string `json:"slug"`
+		Description string `json:"description"`
+		Public      bool   `json:"public"`
+		Archived    bool   `json:"archived"`
+		Origin      *struct {
+			Slug string `json:"slug"`
+		} `json:"origin"`
+		Links struct {
+			Clone []link `json:"clone"`
+		} `json:"links"`
+	} `json:"values"`
+}
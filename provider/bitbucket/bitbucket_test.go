@@ -0,0 +1,112 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TBXark/github-backup/provider/provider"
+)
+
+func TestBitbucket_ListCloudRepos(t *testing.T) {
+	pages := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "secret" {
+			t.Errorf("unexpected credentials %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.EscapedPath() != "/repositories/acme" || r.URL.Query().Get("pagelen") != "100" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if r.URL.Query().Get("page") == "2" {
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"values": []map[string]any{
+					{"slug": "web", "parent": map[string]any{"full_name": "other/web"}},
+				},
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"next": server.URL + "/repositories/acme?pagelen=100&page=2",
+			"values": []map[string]any{
+				{
+					"slug":       "api",
+					"is_private": true,
+					"links": map[string]any{"clone": []map[string]string{
+						{"name": "https", "href": "https://user@bitbucket.org/acme/api.git"},
+						{"name": "ssh", "href": "git@bitbucket.org:acme/api.git"},
+					}},
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	b := NewBitbucket(&Config{Host: server.URL, Username: "user", Token: "secret"})
+	repos, err := b.ListRepos(&provider.Owner{Name: "acme", IsOrg: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages != 2 || len(repos) != 2 {
+		t.Fatalf("expect 2 repos from 2 pages but %d repos from %d pages", len(repos), pages)
+	}
+	if !repos[0].Private || repos[0].CloneURL != "https://bitbucket.org/acme/api.git" || repos[0].SSHURL != "git@bitbucket.org:acme/api.git" {
+		t.Errorf("unexpected repo %+v", repos[0])
+	}
+	if repos[1].Name != "web" || repos[1].Private || !repos[1].Fork {
+		t.Errorf("unexpected repo %+v", repos[1])
+	}
+	if username, password := b.Credentials("secret"); username != "user" || password != "secret" {
+		t.Errorf("unexpected git credentials %s %s", username, password)
+	}
+}
+
+func TestBitbucket_ListServerRepos(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("unexpected credentials %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.EscapedPath() != "/rest/api/1.0/projects/ACME/repos" {
+			t.Errorf("unexpected path %s", r.URL.EscapedPath())
+		}
+		if r.URL.Query().Get("start") == "0" {
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"isLastPage":    false,
+				"nextPageStart": 1,
+				"values": []map[string]any{
+					{"slug": "api", "public": true, "links": map[string]any{"clone": []map[string]string{
+						{"name": "http", "href": "https://admin@git.example.com/scm/acme/api.git"},
+					}}},
+				},
+			})
+			return
+		}
+		if r.URL.Query().Get("start") != "1" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"isLastPage": true,
+			"values": []map[string]any{
+				{"slug": "legacy", "archived": true, "origin": map[string]any{"slug": "upstream"}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	b := NewBitbucket(&Config{Host: server.URL, Server: true, Token: "secret"})
+	repos, err := b.ListRepos(&provider.Owner{Name: "ACME", IsOrg: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("expect 2 repos but %d", len(repos))
+	}
+	if repos[0].Private || repos[0].CloneURL != "https://git.example.com/scm/acme/api.git" {
+		t.Errorf("unexpected repo %+v", repos[0])
+	}
+	if repos[1].Name != "legacy" || !repos[1].Private || !repos[1].Archived || !repos[1].Fork {
+		t.Errorf("unexpected repo %+v", repos[1])
+	}
+}
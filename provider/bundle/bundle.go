@@ -0,0 +1,203 @@
+package bundle
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TBXark/github-backup/provider/provider"
+	"github.com/TBXark/github-backup/utils/git"
+)
+
+const (
+	mirrorDir    = "mirror.git"
+	bundleSuffix = ".bundle"
+	timeLayout   = "20060102T150405Z"
+)
+
+type Config struct {
+	Root      string    `json:"root"`
+	Retention Retention `json:"retention"`
+}
+
+var (
+	_ provider.Provider = &Bundle{}
+	_ provider.Exporter = &Bundle{}
+)
+
+type Bundle struct {
+	conf *Config
+}
+
+func NewBundle(conf *Config) *Bundle {
+	return &Bundle{conf: conf}
+}
+
+func (b *Bundle) LoadRepos(owner *provider.Owner) ([]string, error) {
+	return loadRepos(filepath.Join(b.conf.Root, owner.Name), "")
+}
+
+// loadRepos lists the repos in dir, directories without a mirror or bundles are namespaces of nested repo names.
+func loadRepos(dir, prefix string) ([]string, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]string, 0)
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, dirEntry.Name())
+		bundles, bErr := listBundles(path)
+		if bErr != nil {
+			return nil, bErr
+		}
+		if _, sErr := os.Stat(filepath.Join(path, mirrorDir)); sErr == nil || len(bundles) > 0 {
+			repos = append(repos, prefix+dirEntry.Name())
+			continue
+		}
+		nested, nErr := loadRepos(path, prefix+dirEntry.Name()+"/")
+		if nErr != nil {
+			return nil, nErr
+		}
+		repos = append(repos, nested...)
+	}
+	return repos, nil
+}
+
+func (b *Bundle) MigrateRepo(from *provider.Owner, to *provider.Owner, repo *provider.Repo) (string, error) {
+	repoPath := filepath.Join(b.conf.Root, to.Name, repo.Name)
+	if err := os.MkdirAll(repoPath, os.ModePerm); err != nil {
+		return "", err
+	}
+	gitUrl := repo.SSHURL
+	if gitUrl == "" {
+		gitUrl = repo.CloneURL
+	}
+	mirrorPath := filepath.Join(repoPath, mirrorDir)
+	if err := git.SyncMirror(gitUrl, mirrorPath); err != nil {
+		return "fail", err
+	}
+	status, err := b.createBundle(repoPath, mirrorPath, path.Base(repo.Name))
+	if err != nil {
+		return "fail", err
+	}
+	if err = b.prune(repoPath); err != nil {
+		return "fail", err
+	}
+	return status, nil
+}
+
+func (b *Bundle) createBundle(repoPath, mirrorPath, name string) (string, error) {
+	refs, err := git.Refs(mirrorPath)
+	if err != nil {
+		return "", err
+	}
+	if len(refs) == 0 {
+		return "empty", nil
+	}
+	bundles, err := listBundles(repoPath)
+	if err != nil {
+		return "", err
+	}
+	// skip the snapshot when nothing changed since the latest bundle
+	if len(bundles) > 0 {
+		heads, hErr := git.BundleHeads(bundles[len(bundles)-1].path)
+		if hErr == nil && git.SameRefs(refs, heads) {
+			return "unchanged", nil
+		}
+	}
+	file := filepath.Join(repoPath, fmt.Sprintf("%s-%s%s", name, time.Now().UTC().Format(timeLayout), bundleSuffix))
+	log.Printf("bundle %s", file)
+	tmp := file + ".tmp"
+	if err = git.BundleCreate(mirrorPath, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if err = os.Rename(tmp, file); err != nil {
+		return "", err
+	}
+	return "success", nil
+}
+
+func (b *Bundle) prune(repoPath string) error {
+	bundles, err := listBundles(repoPath)
+	if err != nil {
+		return err
+	}
+	times := make([]time.Time, len(bundles))
+	for i, item := range bundles {
+		times[i] = item.time
+	}
+	keep := b.conf.Retention.Keep(times)
+	for i, item := range bundles {
+		if keep[i] {
+			continue
+		}
+		log.Printf("prune %s", item.path)
+		if err = os.Remove(item.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bundle) ExportRepo(owner *provider.Owner, repo string) (string, error) {
+	bundles, err := listBundles(filepath.Join(b.conf.Root, owner.Name, repo))
+	if err != nil {
+		return "", err
+	}
+	if len(bundles) == 0 {
+		return "", fmt.Errorf("no bundle found for %s/%s", owner.Name, repo)
+	}
+	return bundles[len(bundles)-1].path, nil
+}
+
+func (b *Bundle) DeleteRepo(owner, repo string) (string, error) {
+	repoPath := filepath.Join(b.conf.Root, owner, repo)
+	err := os.RemoveAll(repoPath)
+	if err != nil {
+		return "fail", err
+	}
+	provider.PruneEmptyDirs(filepath.Dir(repoPath), filepath.Join(b.conf.Root, owner))
+	return "success", nil
+}
+
+type bundleFile struct {
+	path string
+	time time.Time
+}
+
+// listBundles returns the bundles of a repository sorted from oldest to newest.
+func listBundles(repoPath string) ([]bundleFile, error) {
+	dirEntries, err := os.ReadDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	bundles := make([]bundleFile, 0)
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || !strings.HasSuffix(name, bundleSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(name, bundleSuffix)
+		if len(stamp) < len(timeLayout) {
+			continue
+		}
+		t, pErr := time.Parse(timeLayout, stamp[len(stamp)-len(timeLayout):])
+		if pErr != nil {
+			continue
+		}
+		bundles = append(bundles, bundleFile{path: filepath.Join(repoPath, name), time: t})
+	}
+	sort.Slice(bundles, func(i, j int) bool {
+		return bundles[i].time.Before(bundles[j].time)
+	})
+	return bundles, nil
+}
@@ -0,0 +1,61 @@
+package bundle
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type Retention struct {
+	KeepLast int `json:"keep_last"`
+	Daily    int `json:"daily"`
+	Weekly   int `json:"weekly"`
+	Monthly  int `json:"monthly"`
+}
+
+// Keep reports for every snapshot time whether it is retained by the policy, an empty policy keeps everything.
+func (r *Retention) Keep(times []time.Time) []bool {
+	keep := make([]bool, len(times))
+	if r.KeepLast <= 0 && r.Daily <= 0 && r.Weekly <= 0 && r.Monthly <= 0 {
+		for i := range keep {
+			keep[i] = true
+		}
+		return keep
+	}
+	order := make([]int, len(times))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return times[order[i]].After(times[order[j]])
+	})
+	for i := 0; i < len(order) && i < r.KeepLast; i++ {
+		keep[order[i]] = true
+	}
+	buckets := []struct {
+		count int
+		key   func(t time.Time) string
+	}{
+		{r.Daily, func(t time.Time) string { return t.UTC().Format("2006-01-02") }},
+		{r.Weekly, func(t time.Time) string {
+			year, week := t.UTC().ISOWeek()
+			return fmt.Sprintf("%d-%d", year, week)
+		}},
+		{r.Monthly, func(t time.Time) string { return t.UTC().Format("2006-01") }},
+	}
+	for _, bucket := range buckets {
+		seen := make(map[string]struct{}, bucket.count)
+		for _, i := range order {
+			if len(seen) >= bucket.count {
+				break
+			}
+			key := bucket.key(times[i])
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			keep[i] = true
+		}
+	}
+	return keep
+}
@@ -0,0 +1,48 @@
+package bundle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetention_Keep(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	var times []time.Time
+	// two snapshots a day for 90 days
+	for i := 0; i < 180; i++ {
+		times = append(times, now.Add(-time.Duration(i)*12*time.Hour))
+	}
+
+	keep := (&Retention{}).Keep(times)
+	for i, k := range keep {
+		if !k {
+			t.Fatalf("empty policy should keep %s", times[i])
+		}
+	}
+
+	cases := []struct {
+		retention Retention
+		expected  int
+	}{
+		{Retention{KeepLast: 3}, 3},
+		{Retention{Daily: 7}, 7},
+		{Retention{KeepLast: 2, Daily: 7}, 8},
+		{Retention{Weekly: 4}, 4},
+		{Retention{Monthly: 12}, 3},
+		{Retention{Daily: 7, Weekly: 4, Monthly: 3}, 12},
+	}
+	for _, c := range cases {
+		count := 0
+		for _, k := range c.retention.Keep(times) {
+			if k {
+				count++
+			}
+		}
+		if count != c.expected {
+			t.Errorf("%+v expect %d kept but %d", c.retention, c.expected, count)
+		}
+	}
+	if !(&Retention{Monthly: 1}).Keep(times)[0] {
+		t.Errorf("newest snapshot should be kept")
+	}
+}
@@ -2,6 +2,7 @@ package gitea
 
 import (
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/TBXark/github-backup/provider/provider"
@@ -15,7 +16,12 @@ type Config struct {
 	AuthUsername string `json:"auth_username"`
 }
 
-var _ provider.Provider = &Gitea{}
+var (
+	_ provider.Provider = &Gitea{}
+	_ provider.Source   = &Gitea{}
+	_ provider.Exporter = &Gitea{}
+	_ provider.Restorer = &Gitea{}
+)
 
 type Gitea struct {
 	conf *Config
@@ -43,10 +49,10 @@ func (g *Gitea) requestModifier() []request.Modifier {
 	}
 }
 
-func (g *Gitea) LoadRepos(owner *provider.Owner) ([]string, error) {
+func (g *Gitea) loadRepos(owner *provider.Owner) ([]reposQuery, error) {
 	limit := 100
 	page := 1
-	repos := make([]string, 0)
+	repos := make([]reposQuery, 0)
 	ownerLower := strings.ToLower(owner.Name)
 	for {
 		url := fmt.Sprintf("%s/%s?limit=%d&page=%d", g.conf.Host, g.buildReposPath(owner.Name, owner.IsOrg), limit, page)
@@ -56,7 +62,7 @@ func (g *Gitea) LoadRepos(owner *provider.Owner) ([]string, error) {
 		}
 		for _, r := range *res {
 			if strings.ToLower(r.Owner.Login) == ownerLower {
-				repos = append(repos, r.Name)
+				repos = append(repos, r)
 			}
 		}
 		if len(*res) < limit {
@@ -67,29 +73,149 @@ func (g *Gitea) LoadRepos(owner *provider.Owner) ([]string, error) {
 	return repos, nil
 }
 
+func (g *Gitea) LoadRepos(owner *provider.Owner) ([]string, error) {
+	res, err := g.loadRepos(owner)
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]string, 0, len(res))
+	for _, r := range res {
+		repos = append(repos, r.Name)
+	}
+	return repos, nil
+}
+
+func (g *Gitea) Service() string {
+	return "gitea"
+}
+
+func (g *Gitea) Credentials(token string) (string, string) {
+	return g.conf.AuthUsername, token
+}
+
+func (g *Gitea) ListRepos(owner *provider.Owner) ([]*provider.SourceRepo, error) {
+	res, err := g.loadRepos(owner)
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]*provider.SourceRepo, 0, len(res))
+	for _, r := range res {
+		repos = append(repos, &provider.SourceRepo{
+			Name:        r.Name,
+			Description: r.Description,
+			Private:     r.Private,
+			Fork:        r.Fork,
+			Archived:    r.Archived,
+			HasWiki:     r.HasWiki,
+			CloneURL:    r.CloneURL,
+			SSHURL:      r.SSHURL,
+		})
+	}
+	return repos, nil
+}
+
 func (g *Gitea) MigrateRepo(from *provider.Owner, to *provider.Owner, repo *provider.Repo) (string, error) {
+	authUsername := repo.AuthUsername
+	if authUsername == "" {
+		authUsername = g.conf.AuthUsername
+	}
+	service := repo.Service
+	if service == "" {
+		service = "github"
+	}
+	// gists have no repository api, mirror them as plain git repositories
+	if repo.Gist {
+		service = "git"
+	}
+	cloneAddr := repo.CloneURL
+	if cloneAddr == "" {
+		cloneAddr = fmt.Sprintf("https://github.com/%s/%s.git", from.Name, repo.Name)
+	}
 	r := migrateRequest{
 		RepoOwner:   to.Name,
 		RepoName:    repo.Name,
 		Description: repo.Description,
 		Private:     true,
 
-		AuthUsername: g.conf.AuthUsername,
+		AuthUsername: authUsername,
 		AuthToken:    repo.AuthToken,
 
 		MirrorInterval: "10m0s",
-		Service:        "github",
-		CloneAddr:      fmt.Sprintf("https://github.com/%s/%s.git", from.Name, repo.Name),
+		Service:        service,
+		CloneAddr:      cloneAddr,
 		Mirror:         true,
+		Wiki:           repo.Wiki,
+		Issues:         repo.Issues,
+		PullRequests:   repo.PullRequests,
+		Labels:         repo.Labels,
+		Milestones:     repo.Milestones,
+		Releases:       repo.Releases,
+	}
+	if repo.LFS {
+		r.LFS = true
+		r.LFSEndpoint = strings.TrimSuffix(cloneAddr, ".git") + ".git/info/lfs"
+	}
+	// plain git migrations only accept password authentication
+	if service == "git" {
+		r.AuthPassword = repo.AuthToken
 	}
 	url := fmt.Sprintf("%s/repos/migrate", g.conf.Host)
 	res, err := request.POST[reposQuery](url, r, g.requestModifier()...)
+	// the mirror already exists and is kept up to date by gitea itself
+	if request.IsStatus(err, http.StatusConflict) {
+		return "exists", nil
+	}
 	if err != nil {
 		return "", err
 	}
 	return (*res).Name, nil
 }
 
+func (g *Gitea) ExportRepo(owner *provider.Owner, repo string) (string, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s", g.conf.Host, owner.Name, repo)
+	res, err := request.GET[reposQuery](url, g.requestModifier()...)
+	if err != nil {
+		return "", err
+	}
+	if res.CloneURL == "" {
+		return "", fmt.Errorf("repo %s/%s not found", owner.Name, repo)
+	}
+	return provider.AuthURL(res.CloneURL, "", g.conf.Token), nil
+}
+
+func (g *Gitea) CreateRepo(owner *provider.Owner, info *provider.RepoInfo) (string, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s", g.conf.Host, owner.Name, info.Name)
+	existing, err := request.GET[reposQuery](url, g.requestModifier()...)
+	if err == nil {
+		return existing.CloneURL, nil
+	}
+	if !request.IsStatus(err, http.StatusNotFound) {
+		return "", err
+	}
+	createURL := fmt.Sprintf("%s/user/repos", g.conf.Host)
+	if owner.IsOrg {
+		createURL = fmt.Sprintf("%s/orgs/%s/repos", g.conf.Host, owner.Name)
+	}
+	res, err := request.POST[reposQuery](createURL, createRequest{
+		Name:        info.Name,
+		Description: info.Description,
+		Private:     info.Private,
+	}, g.requestModifier()...)
+	if err != nil {
+		return "", err
+	}
+	if res.CloneURL == "" {
+		return "", fmt.Errorf("create repo %s/%s failed", owner.Name, info.Name)
+	}
+	if len(info.Topics) > 0 {
+		_, err = request.PUT[struct{}](url+"/topics", map[string][]string{"topics": info.Topics}, g.requestModifier()...)
+		if err != nil {
+			return "", err
+		}
+	}
+	return res.CloneURL, nil
+}
+
 func (g *Gitea) DeleteRepo(owner, repo string) (string, error) {
 	url := fmt.Sprintf("%s/repos/%s/%s", g.conf.Host, owner, repo)
 	resp, err := request.Request("DELETE", url, g.requestModifier()...)
@@ -106,17 +232,39 @@ type migrateRequest struct {
 	Private     bool   `json:"private"`
 
 	AuthUsername string `json:"auth_username"`
+	AuthPassword string `json:"auth_password,omitempty"`
 	AuthToken    string `json:"auth_token"`
 
 	MirrorInterval string `json:"mirror_interval"`
 	Service        string `json:"service"`
 	CloneAddr      string `json:"clone_addr"`
 	Mirror         bool   `json:"mirror"`
+	Wiki           bool   `json:"wiki"`
+	Issues         bool   `json:"issues"`
+	PullRequests   bool   `json:"pull_requests"`
+	Labels         bool   `json:"labels"`
+	Milestones     bool   `json:"milestones"`
+	Releases       bool   `json:"releases"`
+	LFS            bool   `json:"lfs"`
+	LFSEndpoint    string `json:"lfs_endpoint,omitempty"`
+}
+
+type createRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
 }
 
 type reposQuery struct {
-	Name  string `json:"name"`
-	Owner struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+	Fork        bool   `json:"fork"`
+	Archived    bool   `json:"archived"`
+	HasWiki     bool   `json:"has_wiki"`
+	CloneURL    string `json:"clone_url"`
+	SSHURL      string `json:"ssh_url"`
+	Owner       struct {
 		Login string `json:"login"`
 	} `json:"owner"`
 }
@@ -2,33 +2,231 @@ package github
 
 import (
 	"fmt"
+	"log"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/TBXark/github-backup/provider/provider"
 	"github.com/TBXark/github-backup/utils/request"
 )
 
 type Repo struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Private     bool   `json:"isPrivate"`
-	Fork        bool   `json:"isFork"`
-	Archived    bool   `json:"isArchived"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Private     bool       `json:"isPrivate"`
+	Fork        bool       `json:"isFork"`
+	Archived    bool       `json:"isArchived"`
+	HasWiki     bool       `json:"hasWikiEnabled"`
+	Topics      repoTopics `json:"repositoryTopics"`
+	Head        repoHead   `json:"defaultBranchRef"`
+	PushedAt    time.Time  `json:"pushedAt"`
 	Owner       struct {
 		Login string `json:"login"`
 	} `json:"owner"`
 }
 
+var (
+	_ provider.Source            = &Github{}
+	_ provider.RateLimitedSource = &Github{}
+)
+
+type Mode string
+
+const (
+	ModeOwned   Mode = "owned"
+	ModeStarred Mode = "starred"
+)
+
+type StarredNaming string
+
+const (
+	StarredNamingPrefix StarredNaming = "prefix"
+	StarredNamingPath   StarredNaming = "path"
+)
+
+type Config struct {
+	Token         string        `json:"token"`
+	APIURL        string        `json:"api_url"`
+	WebURL        string        `json:"web_url"`
+	SSHHost       string        `json:"ssh_host"`
+	Mode          Mode          `json:"mode"`
+	StarredNaming StarredNaming `json:"starred_naming"`
+	IncludeGists  bool          `json:"include_gists"`
+}
+
+type repoTopics struct {
+	Nodes []struct {
+		Topic struct {
+			Name string `json:"name"`
+		} `json:"topic"`
+	} `json:"nodes"`
+}
+
+type repoHead struct {
+	Target struct {
+		OID string `json:"oid"`
+	} `json:"target"`
+}
+
+type Gist struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Public      bool   `json:"isPublic"`
+	Fork        bool   `json:"isFork"`
+}
+
 type Github struct {
-	Token string
+	conf *Config
+}
+
+func NewGithub(conf *Config) *Github {
+	conf.APIURL = strings.TrimRight(conf.APIURL, "/")
+	if conf.APIURL == "" {
+		conf.APIURL = "https://api.github.com"
+	}
+	conf.WebURL = strings.TrimRight(conf.WebURL, "/")
+	if conf.WebURL == "" {
+		conf.WebURL = "https://github.com"
+	}
+	if conf.SSHHost == "" {
+		conf.SSHHost = "github.com"
+	}
+	if !strings.Contains(conf.SSHHost, "@") {
+		conf.SSHHost = "git@" + conf.SSHHost
+	}
+	if conf.Mode == "" {
+		conf.Mode = ModeOwned
+	}
+	if conf.StarredNaming == "" {
+		conf.StarredNaming = StarredNamingPrefix
+	}
+	return &Github{conf: conf}
+}
+
+func (g *Github) graphqlURL() string {
+	// GitHub Enterprise Server serves REST at /api/v3 and GraphQL at /api/graphql
+	if base, ok := strings.CutSuffix(g.conf.APIURL, "/api/v3"); ok {
+		return base + "/api/graphql"
+	}
+	return g.conf.APIURL + "/graphql"
+}
+
+func (g *Github) RateLimitScope(token string) (string, []request.Modifier) {
+	if token == "" {
+		token = g.conf.Token
+	}
+	return g.conf.APIURL, []request.Modifier{request.WithAuthorization(token, "bearer")}
+}
+
+func (g *Github) CloneURL(owner, repo string) string {
+	return fmt.Sprintf("%s/%s/%s.git", g.conf.WebURL, owner, repo)
+}
+
+func (g *Github) SSHURL(owner, repo string) string {
+	return fmt.Sprintf("%s:%s/%s.git", g.conf.SSHHost, owner, repo)
 }
 
-func NewGithub(token string) *Github {
-	return &Github{Token: token}
+func (g *Github) GistCloneURL(id string) string {
+	if g.conf.WebURL == "https://github.com" {
+		return fmt.Sprintf("https://gist.github.com/%s.git", id)
+	}
+	return fmt.Sprintf("%s/gist/%s.git", g.conf.WebURL, id)
+}
+
+func (g *Github) GistSSHURL(id string) string {
+	if g.conf.SSHHost == "git@github.com" {
+		return fmt.Sprintf("git@gist.github.com:%s.git", id)
+	}
+	return fmt.Sprintf("%s:gist/%s.git", g.conf.SSHHost, id)
+}
+
+func (g *Github) Service() string {
+	return "github"
+}
+
+func (g *Github) Credentials(token string) (string, string) {
+	return "", token
+}
+
+func (g *Github) ListRepos(owner *provider.Owner) ([]*provider.SourceRepo, error) {
+	if g.conf.Mode == ModeStarred {
+		return g.listStarredRepos(owner)
+	}
+	repos, err := g.LoadAllRepos(owner.Name, owner.IsOrg)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*provider.SourceRepo, 0, len(repos))
+	for _, repo := range repos {
+		result = append(result, g.sourceRepo(repo.Name, owner.Name, &repo))
+	}
+	if g.conf.IncludeGists && !owner.IsOrg {
+		gists, gErr := g.LoadAllGists(owner.Name)
+		if gErr != nil {
+			return nil, gErr
+		}
+		for _, gist := range gists {
+			result = append(result, &provider.SourceRepo{
+				Name:        provider.GistNamePrefix + gist.Name,
+				Description: gist.Description,
+				Private:     !gist.Public,
+				Fork:        gist.Fork,
+				CloneURL:    g.GistCloneURL(gist.Name),
+				SSHURL:      g.GistSSHURL(gist.Name),
+				Gist:        true,
+			})
+		}
+	}
+	return result, nil
+}
+
+func (g *Github) listStarredRepos(owner *provider.Owner) ([]*provider.SourceRepo, error) {
+	repos, err := g.LoadStarredRepos(owner.Name)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*provider.SourceRepo, 0, len(repos))
+	for _, repo := range repos {
+		// starred repos of different upstream owners may share a name, so the owner is always part of it
+		name := repo.Owner.Login + "_" + repo.Name
+		if g.conf.StarredNaming == StarredNamingPath {
+			name = repo.Owner.Login + "/" + repo.Name
+		}
+		result = append(result, g.sourceRepo(name, repo.Owner.Login, &repo))
+	}
+	return result, nil
+}
+
+func (g *Github) sourceRepo(name, owner string, repo *Repo) *provider.SourceRepo {
+	topics := make([]string, 0, len(repo.Topics.Nodes))
+	for _, node := range repo.Topics.Nodes {
+		topics = append(topics, node.Topic.Name)
+	}
+	return &provider.SourceRepo{
+		Name:        name,
+		FullName:    owner + "/" + repo.Name,
+		Description: repo.Description,
+		Private:     repo.Private,
+		Fork:        repo.Fork,
+		Archived:    repo.Archived,
+		HasWiki:     repo.HasWiki,
+		Topics:      topics,
+		Head:        repo.Head.Target.OID,
+		PushedAt:    repo.PushedAt,
+		CloneURL:    g.CloneURL(owner, repo.Name),
+		SSHURL:      g.SSHURL(owner, repo.Name),
+	}
 }
 
 func (g *Github) LoadAllRepos(owner string, isOrg bool) ([]Repo, error) {
 	tmpl := `
 query {
+  rateLimit {
+    cost
+    remaining
+    resetAt
+  }
   repositories: %s {
     repositories(
       first: 100,
@@ -44,6 +242,20 @@ query {
         isPrivate
         isFork
 	    isArchived
+        hasWikiEnabled
+        pushedAt
+        repositoryTopics(first: 20) {
+          nodes {
+            topic {
+              name
+            }
+          }
+        }
+        defaultBranchRef {
+          target {
+            oid
+          }
+        }
         owner {
           login
         }
@@ -60,11 +272,11 @@ query {
 	} else {
 		queryType = fmt.Sprintf("repositoryOwner(login: \"%s\")", owner)
 	}
-	token := request.WithAuthorization(g.Token, "bearer")
+	token := request.WithAuthorization(g.conf.Token, "bearer")
 	ownerLower := strings.ToLower(owner)
 	for {
-		query := map[string]string{"query": fmt.Sprintf(tmpl, queryType, next)}
-		data, err := request.POST[reposQuery]("https://api.github.com/graphql", query, token)
+		query := fmt.Sprintf(tmpl, queryType, next)
+		data, err := queryGraphQL[reposQuery](g, query, token)
 		if err != nil {
 			return nil, err
 		}
@@ -76,21 +288,217 @@ query {
 		if !data.Data.Repositories.Repositories.PageInfo.HasNextPage {
 			break
 		}
+		data.Data.RateLimit.wait()
 		next = fmt.Sprintf(`"%s"`, data.Data.Repositories.Repositories.PageInfo.EndCursor)
 	}
 	return repos, nil
 }
 
+func (g *Github) LoadStarredRepos(user string) ([]Repo, error) {
+	tmpl := `
+query {
+  rateLimit {
+    cost
+    remaining
+    resetAt
+  }
+  user(login: "%s") {
+    starredRepositories(
+      first: 100,
+      after: %s
+    ) {
+      pageInfo {
+        hasNextPage
+        endCursor
+      }
+      nodes {
+        name
+        description
+        isPrivate
+        isFork
+        isArchived
+        hasWikiEnabled
+        pushedAt
+        repositoryTopics(first: 20) {
+          nodes {
+            topic {
+              name
+            }
+          }
+        }
+        defaultBranchRef {
+          target {
+            oid
+          }
+        }
+        owner {
+          login
+        }
+      }
+    }
+  }
+}
+`
+	next := "null"
+	var repos []Repo
+	token := request.WithAuthorization(g.conf.Token, "bearer")
+	for {
+		query := fmt.Sprintf(tmpl, user, next)
+		data, err := queryGraphQL[starredQuery](g, query, token)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, data.Data.User.StarredRepositories.Nodes...)
+		if !data.Data.User.StarredRepositories.PageInfo.HasNextPage {
+			break
+		}
+		data.Data.RateLimit.wait()
+		next = fmt.Sprintf(`"%s"`, data.Data.User.StarredRepositories.PageInfo.EndCursor)
+	}
+	return repos, nil
+}
+
+func (g *Github) LoadAllGists(user string) ([]Gist, error) {
+	tmpl := `
+query {
+  rateLimit {
+    cost
+    remaining
+    resetAt
+  }
+  user(login: "%s") {
+    gists(
+      first: 100,
+      after: %s,
+      privacy: ALL
+    ) {
+      pageInfo {
+        hasNextPage
+        endCursor
+      }
+      nodes {
+        name
+        description
+        isPublic
+        isFork
+      }
+    }
+  }
+}
+`
+	next := "null"
+	var gists []Gist
+	token := request.WithAuthorization(g.conf.Token, "bearer")
+	for {
+		query := fmt.Sprintf(tmpl, user, next)
+		data, err := queryGraphQL[gistsQuery](g, query, token)
+		if err != nil {
+			return nil, err
+		}
+		gists = append(gists, data.Data.User.Gists.Nodes...)
+		if !data.Data.User.Gists.PageInfo.HasNextPage {
+			break
+		}
+		data.Data.RateLimit.wait()
+		next = fmt.Sprintf(`"%s"`, data.Data.User.Gists.PageInfo.EndCursor)
+	}
+	return gists, nil
+}
+
+// rateLimitedBackoff is the wait before a query answered with RATE_LIMITED is sent again, the rate limiter of the
+// request package holds the retry back further until the reset when GitHub reported an exhausted budget.
+var rateLimitedBackoff = time.Minute
+
+const maxRateLimitedAttempts = 3
+
+type graphqlError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type graphqlResponse[T any] struct {
+	Data   T              `json:"data"`
+	Errors []graphqlError `json:"errors"`
+}
+
+// queryGraphQL sends query and retries while GitHub answers RATE_LIMITED, any other error fails the query
+// because the data of a failed query is null and would read as the last page.
+func queryGraphQL[T any](g *Github, query string, modifier ...request.Modifier) (*graphqlResponse[T], error) {
+	body := map[string]string{"query": query}
+	for attempt := 1; ; attempt++ {
+		res, err := request.POST[graphqlResponse[T]](g.graphqlURL(), body, modifier...)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Errors) == 0 {
+			return res, nil
+		}
+		limited := slices.ContainsFunc(res.Errors, func(e graphqlError) bool {
+			return e.Type == "RATE_LIMITED"
+		})
+		if limited && attempt < maxRateLimitedAttempts {
+			log.Printf("graphql rate limited, retry in %s", rateLimitedBackoff)
+			time.Sleep(rateLimitedBackoff)
+			continue
+		}
+		messages := make([]string, 0, len(res.Errors))
+		for _, e := range res.Errors {
+			messages = append(messages, strings.TrimSpace(e.Type+" "+e.Message))
+		}
+		return nil, fmt.Errorf("graphql error: %s", strings.Join(messages, "; "))
+	}
+}
+
+type pageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type rateLimit struct {
+	Cost      int       `json:"cost"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"resetAt"`
+}
+
+// wait holds the next page back until the reset when the budget left can not pay for another query of the same cost.
+func (r *rateLimit) wait() {
+	if r.Cost == 0 || r.Remaining >= r.Cost {
+		return
+	}
+	wait := time.Until(r.ResetAt)
+	if wait <= 0 {
+		return
+	}
+	log.Printf("graphql rate limit %d left for a query cost of %d, waiting %s until reset", r.Remaining, r.Cost, wait.Round(time.Second))
+	time.Sleep(wait)
+}
+
+type gistsQuery struct {
+	RateLimit rateLimit `json:"rateLimit"`
+	User      struct {
+		Gists struct {
+			PageInfo pageInfo `json:"pageInfo"`
+			Nodes    []Gist   `json:"nodes"`
+		} `json:"gists"`
+	} `json:"user"`
+}
+
+type starredQuery struct {
+	RateLimit rateLimit `json:"rateLimit"`
+	User      struct {
+		StarredRepositories struct {
+			PageInfo pageInfo `json:"pageInfo"`
+			Nodes    []Repo   `json:"nodes"`
+		} `json:"starredRepositories"`
+	} `json:"user"`
+}
+
 type reposQuery struct {
-	Data struct {
+	RateLimit    rateLimit `json:"rateLimit"`
+	Repositories struct {
 		Repositories struct {
-			Repositories struct {
-				PageInfo struct {
-					HasNextPage bool   `json:"hasNextPage"`
-					EndCursor   string `json:"endCursor"`
-				} `json:"pageInfo"`
-				Nodes []Repo `json:"nodes"`
-			} `json:"repositories"`
+			PageInfo pageInfo `json:"pageInfo"`
+			Nodes    []Repo   `json:"nodes"`
 		} `json:"repositories"`
-	} `json:"data"`
+	} `json:"repositories"`
 }
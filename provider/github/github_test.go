@@ -1,12 +1,18 @@
 package github
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/TBXark/github-backup/provider/provider"
 )
 
 func TestGithub_LoadAllRepos(t *testing.T) {
-	github := NewGithub(os.Getenv("GITHUB_TOKEN"))
+	github := NewGithub(&Config{Token: os.Getenv("GITHUB_TOKEN")})
 	repos, err := github.LoadAllRepos("TBXark", false)
 	if err != nil {
 		t.Fatal(err)
@@ -25,3 +31,126 @@ func TestGithub_LoadAllRepos(t *testing.T) {
 		t.Log(repo.Name)
 	}
 }
+
+func TestGithub_LoadAllReposGraphQLErrors(t *testing.T) {
+	rateLimitedBackoff = 0
+	calls := 0
+	errorType := "RATE_LIMITED"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			_, _ = w.Write([]byte(`{"data": null, "errors": [{"type": "` + errorType + `", "message": "API rate limit exceeded"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"repositories": {"repositories": {"pageInfo": {"hasNextPage": false}, "nodes": [{"name": "repo", "owner": {"login": "tbxark"}}]}}}}`))
+	}))
+	defer server.Close()
+
+	g := NewGithub(&Config{APIURL: server.URL, Token: "secret"})
+	repos, err := g.LoadAllRepos("tbxark", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || calls != 2 {
+		t.Fatalf("expected a retried query, got %d repos from %d calls", len(repos), calls)
+	}
+
+	calls = 0
+	errorType = "NOT_FOUND"
+	if _, err = g.LoadAllRepos("tbxark", false); err == nil || calls != 1 {
+		t.Fatalf("expected a failed query without retry, got %d calls and %v", calls, err)
+	}
+}
+
+func TestGithub_EnterpriseURLs(t *testing.T) {
+	cases := []struct {
+		conf    Config
+		graphql string
+		clone   string
+		ssh     string
+		gist    string
+		gistSSH string
+	}{
+		{
+			conf:    Config{},
+			graphql: "https://api.github.com/graphql",
+			clone:   "https://github.com/tbxark/api.git",
+			ssh:     "git@github.com:tbxark/api.git",
+			gist:    "https://gist.github.com/0123abcd.git",
+			gistSSH: "git@gist.github.com:0123abcd.git",
+		},
+		{
+			conf:    Config{APIURL: "https://ghe.example.com/api/v3/", WebURL: "https://ghe.example.com/", SSHHost: "ghe.example.com"},
+			graphql: "https://ghe.example.com/api/graphql",
+			clone:   "https://ghe.example.com/tbxark/api.git",
+			ssh:     "git@ghe.example.com:tbxark/api.git",
+			gist:    "https://ghe.example.com/gist/0123abcd.git",
+			gistSSH: "git@ghe.example.com:gist/0123abcd.git",
+		},
+	}
+	for _, c := range cases {
+		g := NewGithub(&c.conf)
+		if u := g.graphqlURL(); u != c.graphql {
+			t.Errorf("expect graphql url %s but %s", c.graphql, u)
+		}
+		if u := g.CloneURL("tbxark", "api"); u != c.clone {
+			t.Errorf("expect clone url %s but %s", c.clone, u)
+		}
+		if u := g.SSHURL("tbxark", "api"); u != c.ssh {
+			t.Errorf("expect ssh url %s but %s", c.ssh, u)
+		}
+		if u := g.GistCloneURL("0123abcd"); u != c.gist {
+			t.Errorf("expect gist url %s but %s", c.gist, u)
+		}
+		if u := g.GistSSHURL("0123abcd"); u != c.gistSSH {
+			t.Errorf("expect gist ssh url %s but %s", c.gistSSH, u)
+		}
+	}
+}
+
+func TestGithub_ListStarredAndGists(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/graphql" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		switch query := string(body); {
+		case strings.Contains(query, "starredRepositories("):
+			_, _ = w.Write([]byte(`{"data": {"user": {"starredRepositories": {"pageInfo": {"hasNextPage": false}, "nodes": [{"name": "lib", "owner": {"login": "upstream"}}]}}}}`))
+		case strings.Contains(query, "gists("):
+			_, _ = w.Write([]byte(`{"data": {"user": {"gists": {"pageInfo": {"hasNextPage": false}, "nodes": [{"name": "0123abcd", "isPublic": false}]}}}}`))
+		default:
+			_, _ = w.Write([]byte(`{"data": {"repositories": {"repositories": {"pageInfo": {"hasNextPage": false}, "nodes": [{"name": "api", "owner": {"login": "tbxark"}}]}}}}`))
+		}
+	}))
+	defer server.Close()
+	owner := &provider.Owner{Name: "tbxark"}
+
+	starred := map[StarredNaming]string{
+		"":                  "upstream_lib",
+		StarredNamingPath:   "upstream/lib",
+		StarredNamingPrefix: "upstream_lib",
+	}
+	for naming, expected := range starred {
+		g := NewGithub(&Config{APIURL: server.URL + "/api/v3", WebURL: server.URL, Mode: ModeStarred, StarredNaming: naming})
+		repos, err := g.ListRepos(owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(repos) != 1 || repos[0].Name != expected || repos[0].CloneURL != server.URL+"/upstream/lib.git" {
+			t.Errorf("unexpected starred repos %+v", repos)
+		}
+	}
+
+	g := NewGithub(&Config{APIURL: server.URL + "/api/v3", WebURL: server.URL, IncludeGists: true})
+	repos, err := g.ListRepos(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 || repos[0].Name != "api" {
+		t.Fatalf("unexpected repos %+v", repos)
+	}
+	if gist := repos[1]; gist.Name != "gist-0123abcd" || !gist.Gist || !gist.Private || gist.CloneURL != server.URL+"/gist/0123abcd.git" {
+		t.Errorf("unexpected gist %+v", gist)
+	}
+}
@@ -0,0 +1,82 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/TBXark/github-backup/provider/provider"
+	"github.com/TBXark/github-backup/utils/request"
+)
+
+var _ provider.MetadataSource = &Github{}
+
+func (g *Github) restModifier(token string) []request.Modifier {
+	return []request.Modifier{
+		request.WithAuthorization(token, "bearer"),
+		request.WithHeader("Accept", "application/vnd.github+json"),
+	}
+}
+
+func (g *Github) ExportMetadata(repo *provider.SourceRepo, token string, kind provider.MetadataKind, since time.Time) ([]json.RawMessage, error) {
+	if token == "" {
+		token = g.conf.Token
+	}
+	path := ""
+	incremental := false
+	switch kind {
+	case provider.MetadataKindIssues:
+		path, incremental = "issues?state=all", true
+	case provider.MetadataKindIssueComments:
+		path, incremental = "issues/comments?sort=updated", true
+	case provider.MetadataKindPullRequests:
+		path = "pulls?state=all&sort=updated&direction=desc"
+	case provider.MetadataKindReviewComments:
+		path, incremental = "pulls/comments?sort=updated", true
+	case provider.MetadataKindLabels:
+		path = "labels?"
+	case provider.MetadataKindMilestones:
+		path = "milestones?state=all"
+	default:
+		return nil, fmt.Errorf("unsupported metadata kind: %s", kind)
+	}
+	if incremental && !since.IsZero() {
+		path += "&since=" + since.UTC().Format(time.RFC3339)
+	}
+	limit := 100
+	page := 1
+	items := make([]json.RawMessage, 0)
+	for {
+		url := fmt.Sprintf("%s/repos/%s/%s&per_page=%d&page=%d", g.conf.APIURL, repo.FullName, path, limit, page)
+		res, err := request.GET[[]json.RawMessage](url, g.restModifier(token)...)
+		if err != nil {
+			return nil, err
+		}
+		// pull requests can not be filtered by since, they are sorted by update time instead
+		if kind == provider.MetadataKindPullRequests && !since.IsZero() {
+			for _, item := range *res {
+				if updatedBefore(item, since) {
+					return items, nil
+				}
+				items = append(items, item)
+			}
+		} else {
+			items = append(items, *res...)
+		}
+		if len(*res) < limit {
+			break
+		}
+		page += 1
+	}
+	return items, nil
+}
+
+func updatedBefore(item json.RawMessage, since time.Time) bool {
+	var v struct {
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	if err := json.Unmarshal(item, &v); err != nil {
+		return false
+	}
+	return v.UpdatedAt.Before(since)
+}
@@ -0,0 +1,82 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/TBXark/github-backup/provider/provider"
+	"github.com/TBXark/github-backup/utils/request"
+)
+
+var _ provider.ReleaseSource = &Github{}
+
+func (g *Github) ListReleases(repo *provider.SourceRepo, token string) ([]*provider.Release, error) {
+	if token == "" {
+		token = g.conf.Token
+	}
+	limit := 100
+	page := 1
+	releases := make([]*provider.Release, 0)
+	for {
+		url := fmt.Sprintf("%s/repos/%s/releases?per_page=%d&page=%d", g.conf.APIURL, repo.FullName, limit, page)
+		res, err := request.GET[[]json.RawMessage](url, g.restModifier(token)...)
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range *res {
+			var r releaseQuery
+			if err = json.Unmarshal(raw, &r); err != nil {
+				return nil, err
+			}
+			release := &provider.Release{
+				TagName: r.TagName,
+				Raw:     raw,
+			}
+			for _, a := range r.Assets {
+				release.Assets = append(release.Assets, &provider.ReleaseAsset{
+					Name:        a.Name,
+					Size:        a.Size,
+					Digest:      a.Digest,
+					DownloadURL: a.URL,
+				})
+			}
+			releases = append(releases, release)
+		}
+		if len(*res) < limit {
+			break
+		}
+		page += 1
+	}
+	return releases, nil
+}
+
+func (g *Github) DownloadAsset(asset *provider.ReleaseAsset, token string) (io.ReadCloser, error) {
+	if token == "" {
+		token = g.conf.Token
+	}
+	resp, err := request.Request("GET", asset.DownloadURL,
+		request.WithAuthorization(token, "bearer"),
+		request.WithHeader("Accept", "application/octet-stream"),
+		request.WithTimeout(0),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("download %s: %s", asset.Name, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+type releaseQuery struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name   string `json:"name"`
+		Size   int64  `json:"size"`
+		Digest string `json:"digest"`
+		URL    string `json:"url"`
+	} `json:"assets"`
+}
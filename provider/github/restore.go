@@ -0,0 +1,54 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/TBXark/github-backup/provider/provider"
+	"github.com/TBXark/github-backup/utils/request"
+)
+
+var _ provider.Restorer = &Github{}
+
+func (g *Github) CreateRepo(owner *provider.Owner, info *provider.RepoInfo) (string, error) {
+	repoURL := fmt.Sprintf("%s/repos/%s/%s", g.conf.APIURL, owner.Name, info.Name)
+	_, err := request.GET[restRepoQuery](repoURL, g.restModifier(g.conf.Token)...)
+	if err != nil && !request.IsStatus(err, http.StatusNotFound) {
+		return "", err
+	}
+	if err != nil {
+		createURL := fmt.Sprintf("%s/user/repos", g.conf.APIURL)
+		if owner.IsOrg {
+			createURL = fmt.Sprintf("%s/orgs/%s/repos", g.conf.APIURL, owner.Name)
+		}
+		res, cErr := request.POST[restRepoQuery](createURL, createRepoRequest{
+			Name:        info.Name,
+			Description: info.Description,
+			Private:     info.Private,
+		}, g.restModifier(g.conf.Token)...)
+		if cErr != nil {
+			return "", cErr
+		}
+		if res.ID == 0 {
+			return "", fmt.Errorf("create repo %s/%s failed: %s", owner.Name, info.Name, res.Message)
+		}
+	}
+	if len(info.Topics) > 0 {
+		_, err = request.PUT[struct{}](repoURL+"/topics", map[string][]string{"names": info.Topics}, g.restModifier(g.conf.Token)...)
+		if err != nil {
+			return "", err
+		}
+	}
+	return g.CloneURL(owner.Name, info.Name), nil
+}
+
+type createRepoRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+}
+
+type restRepoQuery struct {
+	ID      int64  `json:"id"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,356 @@
+package gitlab
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/TBXark/github-backup/provider/provider"
+	"github.com/TBXark/github-backup/utils/git"
+	"github.com/TBXark/github-backup/utils/request"
+)
+
+type SubgroupPath string
+
+const (
+	SubgroupPathFlatten  SubgroupPath = "flatten"
+	SubgroupPathPreserve SubgroupPath = "preserve"
+)
+
+type Config struct {
+	Host              string       `json:"host"`
+	Token             string       `json:"token"`
+	IncludeSubgroups  bool         `json:"include_subgroups"`
+	SubgroupPath      SubgroupPath `json:"subgroup_path"`
+	SubgroupSeparator string       `json:"subgroup_separator"`
+	Mirror            bool         `json:"mirror"`
+	Visibility        string       `json:"visibility"`
+}
+
+var (
+	_ provider.Provider = &Gitlab{}
+	_ provider.Source   = &Gitlab{}
+	_ provider.Exporter = &Gitlab{}
+	_ provider.Restorer = &Gitlab{}
+)
+
+type Gitlab struct {
+	conf *Config
+}
+
+func NewGitlab(conf *Config) *Gitlab {
+	conf.Host = strings.TrimRight(conf.Host, "/")
+	if conf.Host == "" {
+		conf.Host = "https://gitlab.com"
+	}
+	if !strings.HasSuffix(conf.Host, "/api/v4") {
+		conf.Host += "/api/v4"
+	}
+	if conf.SubgroupPath == "" {
+		conf.SubgroupPath = SubgroupPathFlatten
+	}
+	if conf.SubgroupSeparator == "" {
+		conf.SubgroupSeparator = "-"
+	}
+	if conf.Visibility == "" {
+		conf.Visibility = "private"
+	}
+	return &Gitlab{conf: conf}
+}
+
+func (g *Gitlab) buildProjectsURL(owner *provider.Owner, limit, page int) (string, error) {
+	query := map[string]string{
+		"per_page": strconv.Itoa(limit),
+		"page":     strconv.Itoa(page),
+	}
+	if owner.IsOrg {
+		query["include_subgroups"] = strconv.FormatBool(g.conf.IncludeSubgroups)
+		return request.URL(fmt.Sprintf("%s/groups/%s/projects", g.conf.Host, url.PathEscape(owner.Name)), query)
+	}
+	return request.URL(fmt.Sprintf("%s/users/%s/projects", g.conf.Host, url.PathEscape(owner.Name)), query)
+}
+
+func (g *Gitlab) requestModifier() []request.Modifier {
+	return []request.Modifier{
+		request.WithAuthorization(g.conf.Token, "Bearer"),
+	}
+}
+
+func (g *Gitlab) Service() string {
+	return "gitlab"
+}
+
+func (g *Gitlab) Credentials(token string) (string, string) {
+	return "oauth2", token
+}
+
+func (g *Gitlab) ListRepos(owner *provider.Owner) ([]*provider.SourceRepo, error) {
+	limit := 100
+	page := 1
+	repos := make([]*provider.SourceRepo, 0)
+	for {
+		projectsURL, err := g.buildProjectsURL(owner, limit, page)
+		if err != nil {
+			return nil, err
+		}
+		res, err := request.GET[[]projectQuery](projectsURL, g.requestModifier()...)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range *res {
+			name, ok := g.repoName(owner.Name, p.PathWithNamespace)
+			if !ok {
+				continue
+			}
+			repos = append(repos, &provider.SourceRepo{
+				Name:        name,
+				Description: p.Description,
+				Private:     p.Visibility != "public",
+				Fork:        p.ForkedFromProject != nil,
+				Archived:    p.Archived,
+				HasWiki:     p.WikiEnabled,
+				CloneURL:    p.HttpURLToRepo,
+				SSHURL:      p.SshURLToRepo,
+			})
+		}
+		if len(*res) < limit {
+			break
+		}
+		page += 1
+	}
+	return repos, nil
+}
+
+func (g *Gitlab) repoName(owner, fullPath string) (string, bool) {
+	prefix := strings.ToLower(owner) + "/"
+	if !strings.HasPrefix(strings.ToLower(fullPath), prefix) {
+		return "", false
+	}
+	name := fullPath[len(prefix):]
+	if g.conf.SubgroupPath == SubgroupPathFlatten {
+		name = strings.ReplaceAll(name, "/", g.conf.SubgroupSeparator)
+	}
+	return name, true
+}
+
+func (g *Gitlab) LoadRepos(owner *provider.Owner) ([]string, error) {
+	limit := 100
+	page := 1
+	repos := make([]string, 0)
+	prefix := strings.ToLower(owner.Name) + "/"
+	for {
+		projectsURL, err := g.buildProjectsURL(owner, limit, page)
+		if err != nil {
+			return nil, err
+		}
+		res, err := request.GET[[]projectQuery](projectsURL, g.requestModifier()...)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range *res {
+			// only projects directly in the namespace are managed by the backup
+			if strings.ToLower(p.PathWithNamespace) == prefix+strings.ToLower(p.Path) {
+				repos = append(repos, p.Path)
+			}
+		}
+		if len(*res) < limit {
+			break
+		}
+		page += 1
+	}
+	return repos, nil
+}
+
+func (g *Gitlab) projectURL(owner, repo string) string {
+	return fmt.Sprintf("%s/projects/%s", g.conf.Host, url.PathEscape(owner+"/"+repo))
+}
+
+func (g *Gitlab) namespaceID(owner *provider.Owner) (int, error) {
+	path := "namespaces"
+	if owner.IsOrg {
+		path = "groups"
+	}
+	res, err := request.GET[namespaceQuery](fmt.Sprintf("%s/%s/%s", g.conf.Host, path, url.PathEscape(owner.Name)), g.requestModifier()...)
+	if err != nil {
+		return 0, err
+	}
+	if res.ID == 0 {
+		return 0, fmt.Errorf("namespace %s not found: %s", owner.Name, res.Message)
+	}
+	return res.ID, nil
+}
+
+// loadProject returns nil when the project does not exist.
+func (g *Gitlab) loadProject(owner, repo string) (*projectQuery, error) {
+	res, err := request.GET[projectQuery](g.projectURL(owner, repo), g.requestModifier()...)
+	if request.IsStatus(err, http.StatusNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (g *Gitlab) MigrateRepo(from *provider.Owner, to *provider.Owner, repo *provider.Repo) (string, error) {
+	username := repo.AuthUsername
+	if username == "" {
+		username = "git"
+	}
+	existing, err := g.loadProject(to.Name, repo.Name)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		if existing.Mirror {
+			resp, pErr := request.Request("POST", fmt.Sprintf("%s/projects/%d/mirror/pull", g.conf.Host, existing.ID), g.requestModifier()...)
+			if pErr != nil {
+				return "", pErr
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return resp.Status, nil
+			}
+			log.Printf("trigger pull mirror of %s/%s error: %s, pushing instead", to.Name, repo.Name, resp.Status)
+		}
+		// imported projects and instances without pull mirrors (GitLab Premium) are updated by pushes
+		return g.pushUpdate(existing, repo, username)
+	}
+	namespaceID, err := g.namespaceID(to)
+	if err != nil {
+		return "", err
+	}
+	r := createProjectRequest{
+		Name:        repo.Name,
+		Path:        repo.Name,
+		NamespaceID: namespaceID,
+		Description: repo.Description,
+		Visibility:  g.conf.Visibility,
+		ImportURL:   provider.AuthURL(repo.CloneURL, username, repo.AuthToken),
+		Mirror:      g.conf.Mirror,
+	}
+	res, err := request.POST[projectQuery](fmt.Sprintf("%s/projects", g.conf.Host), r, g.requestModifier()...)
+	if err != nil {
+		return "", err
+	}
+	if res.ID == 0 {
+		return "", fmt.Errorf("create project %s/%s failed: %v", to.Name, repo.Name, res.Message)
+	}
+	return res.PathWithNamespace, nil
+}
+
+func (g *Gitlab) pushUpdate(project *projectQuery, repo *provider.Repo, username string) (string, error) {
+	dir, err := os.MkdirTemp("", "github-backup-gitlab-")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	mirror := filepath.Join(dir, repo.Name+".git")
+	if err = git.CloneMirror(provider.AuthURL(repo.CloneURL, username, repo.AuthToken), mirror); err != nil {
+		return "", err
+	}
+	pushURL := provider.AuthURL(project.HttpURLToRepo, "oauth2", g.conf.Token)
+	if err = git.Run(mirror, "push", "--prune", pushURL, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"); err != nil {
+		return "", err
+	}
+	return "pushed", nil
+}
+
+func (g *Gitlab) ExportRepo(owner *provider.Owner, repo string) (string, error) {
+	res, err := request.GET[projectQuery](g.projectURL(owner.Name, repo), g.requestModifier()...)
+	if err != nil {
+		return "", err
+	}
+	if res.ID == 0 {
+		return "", fmt.Errorf("project %s/%s not found: %v", owner.Name, repo, res.Message)
+	}
+	return provider.AuthURL(res.HttpURLToRepo, "oauth2", g.conf.Token), nil
+}
+
+func (g *Gitlab) CreateRepo(owner *provider.Owner, info *provider.RepoInfo) (string, error) {
+	existing, err := g.loadProject(owner.Name, info.Name)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return existing.HttpURLToRepo, nil
+	}
+	namespaceID, err := g.namespaceID(owner)
+	if err != nil {
+		return "", err
+	}
+	visibility := "public"
+	if info.Private {
+		visibility = "private"
+	}
+	r := createProjectRequest{
+		Name:        info.Name,
+		Path:        info.Name,
+		NamespaceID: namespaceID,
+		Description: info.Description,
+		Visibility:  visibility,
+		Topics:      info.Topics,
+	}
+	res, err := request.POST[projectQuery](fmt.Sprintf("%s/projects", g.conf.Host), r, g.requestModifier()...)
+	if err != nil {
+		return "", err
+	}
+	if res.ID == 0 {
+		return "", fmt.Errorf("create project %s/%s failed: %v", owner.Name, info.Name, res.Message)
+	}
+	return res.HttpURLToRepo, nil
+}
+
+func (g *Gitlab) DeleteRepo(owner, repo string) (string, error) {
+	resp, err := request.Request("DELETE", g.projectURL(owner, repo), g.requestModifier()...)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if err = request.CheckStatus(resp); err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+type createProjectRequest struct {
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	NamespaceID int      `json:"namespace_id"`
+	Description string   `json:"description"`
+	Visibility  string   `json:"visibility"`
+	ImportURL   string   `json:"import_url,omitempty"`
+	Mirror      bool     `json:"mirror,omitempty"`
+	Topics      []string `json:"topics,omitempty"`
+}
+
+type namespaceQuery struct {
+	ID      int    `json:"id"`
+	Message string `json:"message"`
+}
+
+type projectQuery struct {
+	ID                int    `json:"id"`
+	Message           any    `json:"message"`
+	Mirror            bool   `json:"mirror"`
+	Path              string `json:"path"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	Description       string `json:"description"`
+	Visibility        string `json:"visibility"`
+	Archived          bool   `json:"archived"`
+	WikiEnabled       bool   `json:"wiki_enabled"`
+	ForkedFromProject *struct {
+		ID int `json:"id"`
+	} `json:"forked_from_project"`
+	HttpURLToRepo string `json:"http_url_to_repo"`
+	SshURLToRepo  string `json:"ssh_url_to_repo"`
+}
@@ -4,17 +4,26 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/TBXark/github-backup/provider/provider"
+	"github.com/TBXark/github-backup/utils/git"
 )
 
 type UpdateAction string
 
 const (
-	UpdateActionPull  = "pull"
-	UpdateActionFetch = "fetch"
+	UpdateActionPull   = "pull"
+	UpdateActionFetch  = "fetch"
+	UpdateActionMirror = "mirror"
+)
+
+const (
+	wikiSuffix     = ".wiki"
+	metadataSuffix = ".metadata"
+	gistsDir       = "gists"
 )
 
 type Config struct {
@@ -23,7 +32,10 @@ type Config struct {
 	Action    UpdateAction `json:"action"`
 }
 
-var _ provider.Provider = &Local{}
+var (
+	_ provider.Provider = &Local{}
+	_ provider.Exporter = &Local{}
+)
 
 type Local struct {
 	conf *Config
@@ -34,20 +46,50 @@ func NewLocal(conf *Config) *Local {
 }
 
 func (l *Local) LoadRepos(owner *provider.Owner) ([]string, error) {
-	ownerPath := filepath.Join(l.conf.Root, owner.Name)
-	dirEntries, err := os.ReadDir(ownerPath)
+	repos, err := loadRepos(filepath.Join(l.conf.Root, owner.Name), "")
+	if err != nil {
+		return nil, err
+	}
+	for i, repo := range repos {
+		if id, ok := strings.CutPrefix(repo, gistsDir+"/"); ok {
+			repos[i] = provider.GistNamePrefix + id
+		}
+	}
+	return repos, nil
+}
+
+// repoPath returns the directory of repo, gists named gist-<id> are kept apart from the repos in <owner>/gists/<id>.
+func (l *Local) repoPath(owner, repo string) string {
+	if id, ok := strings.CutPrefix(repo, provider.GistNamePrefix); ok {
+		return filepath.Join(l.conf.Root, owner, gistsDir, id)
+	}
+	return filepath.Join(l.conf.Root, owner, repo)
+}
+
+// loadRepos lists the repos in dir, directories that are not git repositories are namespaces of nested repo names.
+func loadRepos(dir, prefix string) ([]string, error) {
+	dirEntries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
 	repos := make([]string, 0)
 	for _, dirEntry := range dirEntries {
-		if dirEntry.IsDir() {
-			if !isGitRepository(filepath.Join(ownerPath, dirEntry.Name())) {
-				log.Printf("skipping non-git dir %s/%s", owner.Name, dirEntry.Name())
-				continue
-			}
-			repos = append(repos, dirEntry.Name())
+		if !dirEntry.IsDir() || isSidecarOf(dirEntries, dirEntry.Name()) {
+			continue
 		}
+		path := filepath.Join(dir, dirEntry.Name())
+		if git.IsRepository(path) {
+			repos = append(repos, prefix+dirEntry.Name())
+			continue
+		}
+		nested, nErr := loadRepos(path, prefix+dirEntry.Name()+"/")
+		if nErr != nil {
+			return nil, nErr
+		}
+		if len(nested) == 0 {
+			log.Printf("skipping non-git dir %s", path)
+		}
+		repos = append(repos, nested...)
 	}
 	return repos, nil
 }
@@ -56,60 +98,145 @@ func (l *Local) MigrateRepo(from *provider.Owner, to *provider.Owner, repo *prov
 	if l.conf.Questions && !question(fmt.Sprintf("Are you sure you want to migrate %s/%s to %s/%s? [y/n]: ", from.Name, repo.Name, to.Name, repo.Name)) {
 		return "skip", nil
 	}
-	ownerPath := filepath.Join(l.conf.Root, to.Name)
-	_, err := os.Stat(ownerPath)
+	repoPath := l.repoPath(to.Name, repo.Name)
+	parentPath := filepath.Dir(repoPath)
+	_, err := os.Stat(parentPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			if e := os.MkdirAll(ownerPath, os.ModePerm); e != nil {
+			if e := os.MkdirAll(parentPath, os.ModePerm); e != nil {
 				return "", e
 			}
 		} else {
 			return "", err
 		}
 	}
-	repoPath := filepath.Join(ownerPath, repo.Name)
-	_, err = os.Stat(repoPath)
-	gitUrl := fmt.Sprintf("git@github.com:%s/%s.git", from.Name, repo.Name)
+	gitUrl := repo.SSHURL
+	if gitUrl == "" {
+		gitUrl = repo.CloneURL
+	}
+	if gitUrl == "" {
+		gitUrl = fmt.Sprintf("git@github.com:%s/%s.git", from.Name, repo.Name)
+	}
+	if repo.Gist {
+		return migrateMirror(gitUrl, repoPath)
+	}
+	err = l.syncRepo(gitUrl, repoPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			err = gitClone(gitUrl, repoPath)
-			if err != nil {
-				return "success", err
-			}
-		} else {
+		return "fail", err
+	}
+	if repo.LFS {
+		err = gitLFSFetch(repoPath)
+		if err != nil {
+			return "fail", err
+		}
+	}
+	if repo.Wiki {
+		wikiUrl := strings.TrimSuffix(gitUrl, ".git") + ".wiki.git"
+		// a wiki that is enabled but has no pages yet can not be cloned
+		if wErr := l.syncRepo(wikiUrl, repoPath+wikiSuffix); wErr != nil {
+			log.Printf("sync wiki %s error: %s", repo.Name, wErr.Error())
+		}
+	}
+	return "success", nil
+}
+
+func (l *Local) syncRepo(gitUrl, repoPath string) error {
+	if l.conf.Action == UpdateActionMirror {
+		_, err := migrateMirror(gitUrl, repoPath)
+		return err
+	}
+	_, err := os.Stat(repoPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		err = gitClone(gitUrl, repoPath)
+		if err != nil {
+			return err
+		}
+	}
+	return gitUpdateLocal(repoPath, l.conf.Action)
+}
+
+func migrateMirror(gitUrl, repoPath string) (string, error) {
+	_, err := os.Stat(repoPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
 			return "", err
 		}
+		err = gitCloneMirror(gitUrl, repoPath)
+		if err != nil {
+			return "fail", err
+		}
+		return "success", nil
 	}
-	err = gitUpdateLocal(repoPath, l.conf.Action)
+	err = gitRemoteUpdate(repoPath)
 	if err != nil {
 		return "fail", err
 	}
 	return "success", nil
 }
 
+func (l *Local) ExportRepo(owner *provider.Owner, repo string) (string, error) {
+	repoPath := l.repoPath(owner.Name, repo)
+	if !git.IsRepository(repoPath) {
+		return "", fmt.Errorf("%s is not a git repository", repoPath)
+	}
+	return repoPath, nil
+}
+
 func (l *Local) DeleteRepo(owner, repo string) (string, error) {
 	if l.conf.Questions && !question(fmt.Sprintf("Are you sure you want to delete %s/%s? [y/n]: ", owner, repo)) {
 		return "skip", nil
 	}
-	repoPath := filepath.Join(l.conf.Root, owner, repo)
+	repoPath := l.repoPath(owner, repo)
 	err := os.RemoveAll(repoPath)
 	if err != nil {
 		return "fail", err
 	}
+	for _, suffix := range []string{wikiSuffix, metadataSuffix} {
+		err = os.RemoveAll(repoPath + suffix)
+		if err != nil {
+			return "fail", err
+		}
+	}
+	provider.PruneEmptyDirs(filepath.Dir(repoPath), filepath.Join(l.conf.Root, owner))
 	return "success", nil
 }
 
-func isGitRepository(path string) bool {
-	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
-	cmd.Dir = path
-	err := cmd.Run()
-	return err == nil
+func isSidecarOf(dirEntries []os.DirEntry, name string) bool {
+	for _, suffix := range []string{wikiSuffix, metadataSuffix} {
+		base, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+		for _, dirEntry := range dirEntries {
+			if dirEntry.IsDir() && dirEntry.Name() == base {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func gitClone(url, path string) error {
 	log.Printf("cloning %s", url)
-	cmd := exec.Command("git", "clone", url, path)
-	return cmd.Run()
+	return git.Run("", "clone", url, path)
+}
+
+func gitCloneMirror(url, path string) error {
+	log.Printf("cloning mirror %s", url)
+	return git.CloneMirror(url, path)
+}
+
+func gitRemoteUpdate(path string) error {
+	log.Printf("remote update %s", path)
+	return git.RemoteUpdate(path)
+}
+
+func gitLFSFetch(path string) error {
+	log.Printf("lfs fetch %s", path)
+	return git.Run(path, "lfs", "fetch", "--all")
 }
 
 func gitUpdateLocal(path string, action UpdateAction) error {
@@ -117,16 +244,15 @@ func gitUpdateLocal(path string, action UpdateAction) error {
 	if action != UpdateActionPull && action != UpdateActionFetch {
 		return fmt.Errorf("unsupported action: %s", action)
 	}
-	cmd := exec.Command("git", string(action), "--all")
-	cmd.Dir = path
-	err := cmd.Run()
-	if err != nil {
-		return err
-	}
-	return nil
+	return git.Run(path, string(action), "--all")
 }
 
+// questionLock keeps prompts of concurrent migrations from interleaving on stdin
+var questionLock sync.Mutex
+
 func question(message string) bool {
+	questionLock.Lock()
+	defer questionLock.Unlock()
 	var response string
 	fmt.Print(message)
 	_, err := fmt.Scanln(&response)
@@ -0,0 +1,73 @@
+package local
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/TBXark/github-backup/provider/provider"
+)
+
+func TestLocal_LoadRepos(t *testing.T) {
+	root := t.TempDir()
+	owner := &provider.Owner{Name: "tbxark"}
+	for _, name := range []string{"gists/0123abcd", "gists/4567ef", "backup", "backup.wiki"} {
+		if out, err := exec.Command("git", "init", "--quiet", filepath.Join(root, owner.Name, name)).CombinedOutput(); err != nil {
+			t.Fatalf("git init %s: %s %s", name, err, out)
+		}
+	}
+	l := NewLocal(&Config{Root: root})
+	repos, err := l.LoadRepos(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(repos)
+	if !slices.Equal(repos, []string{"backup", "gist-0123abcd", "gist-4567ef"}) {
+		t.Errorf("unexpected repos %v", repos)
+	}
+
+	if path, eErr := l.ExportRepo(owner, "gist-0123abcd"); eErr != nil || path != filepath.Join(root, owner.Name, "gists", "0123abcd") {
+		t.Errorf("unexpected gist export %s %v", path, eErr)
+	}
+	for _, name := range []string{"gist-0123abcd", "gist-4567ef"} {
+		if _, err = l.DeleteRepo(owner.Name, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = os.Stat(filepath.Join(root, owner.Name, "gists")); !os.IsNotExist(err) {
+		t.Errorf("expect empty gists dir to be removed but %v", err)
+	}
+}
+
+func TestLocal_NestedRepos(t *testing.T) {
+	root := t.TempDir()
+	owner := &provider.Owner{Name: "acme"}
+	for _, name := range []string{"api", "tools/cli", "tools/cli.wiki", "tools/deep/lib"} {
+		if out, err := exec.Command("git", "init", "--quiet", filepath.Join(root, owner.Name, name)).CombinedOutput(); err != nil {
+			t.Fatalf("git init %s: %s %s", name, err, out)
+		}
+	}
+	l := NewLocal(&Config{Root: root})
+	repos, err := l.LoadRepos(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(repos)
+	if !slices.Equal(repos, []string{"api", "tools/cli", "tools/deep/lib"}) {
+		t.Errorf("unexpected repos %v", repos)
+	}
+
+	for _, name := range []string{"tools/deep/lib", "tools/cli"} {
+		if _, err = l.DeleteRepo(owner.Name, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = os.Stat(filepath.Join(root, owner.Name, "tools")); !os.IsNotExist(err) {
+		t.Errorf("expect empty namespace to be removed but %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(root, owner.Name, "api")); err != nil {
+		t.Errorf("expect api to be kept but %v", err)
+	}
+}
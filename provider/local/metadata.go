@@ -0,0 +1,183 @@
+package local
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TBXark/github-backup/provider/provider"
+)
+
+var (
+	_ provider.MetadataStore = &Local{}
+	_ provider.ReleaseStore  = &Local{}
+	_ provider.RepoInfoStore = &Local{}
+)
+
+func (l *Local) metadataPath(owner *provider.Owner, repo string, kind provider.MetadataKind) string {
+	return filepath.Join(l.conf.Root, owner.Name, repo+metadataSuffix, string(kind)+".json")
+}
+
+func (l *Local) LoadMetadata(owner *provider.Owner, repo string, kind provider.MetadataKind) (*provider.Metadata, error) {
+	data, err := os.ReadFile(l.metadataPath(owner, repo, kind))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &provider.Metadata{Version: provider.MetadataVersion, Kind: kind}, nil
+		}
+		return nil, err
+	}
+	metadata := &provider.Metadata{}
+	err = json.Unmarshal(data, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.Version != provider.MetadataVersion {
+		return nil, fmt.Errorf("unsupported metadata version %d of %s/%s", metadata.Version, repo, kind)
+	}
+	return metadata, nil
+}
+
+func (l *Local) SaveMetadata(owner *provider.Owner, repo string, metadata *provider.Metadata) error {
+	path := l.metadataPath(owner, repo, metadata.Kind)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func (l *Local) repoInfoPath(owner *provider.Owner, repo string) string {
+	return filepath.Join(l.conf.Root, owner.Name, repo+metadataSuffix, "repository.json")
+}
+
+func (l *Local) LoadRepoInfo(owner *provider.Owner, repo string) (*provider.RepoInfo, error) {
+	data, err := os.ReadFile(l.repoInfoPath(owner, repo))
+	if err != nil {
+		return nil, err
+	}
+	info := &provider.RepoInfo{}
+	err = json.Unmarshal(data, info)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (l *Local) SaveRepoInfo(owner *provider.Owner, info *provider.RepoInfo) error {
+	path := l.repoInfoPath(owner, info.Name)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func (l *Local) SaveRelease(owner *provider.Owner, repo string, release *provider.Release, download provider.AssetDownloader) error {
+	tag := url.PathEscape(release.TagName)
+	if tag == "" || tag == "." || tag == ".." {
+		return fmt.Errorf("invalid release tag %q", release.TagName)
+	}
+	dir := filepath.Join(l.conf.Root, owner.Name, repo+metadataSuffix, "releases", tag)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	err := writeFileAtomic(filepath.Join(dir, "release.json"), release.Raw)
+	if err != nil {
+		return err
+	}
+	for _, asset := range release.Assets {
+		path := filepath.Join(dir, filepath.Base(asset.Name))
+		if isAssetDownloaded(path, asset) {
+			continue
+		}
+		log.Printf("downloading %s/%s %s", repo, release.TagName, asset.Name)
+		if err = saveAsset(path, asset, download); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isAssetDownloaded(path string, asset *provider.ReleaseAsset) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != asset.Size {
+		return false
+	}
+	algorithm, expected, ok := strings.Cut(asset.Digest, ":")
+	if !ok || algorithm != "sha256" {
+		return true
+	}
+	digest, err := fileSHA256(path)
+	if err != nil {
+		return false
+	}
+	return digest == expected
+}
+
+func saveAsset(path string, asset *provider.ReleaseAsset, download provider.AssetDownloader) error {
+	body, err := download(asset)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), body)
+	if cErr := file.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	algorithm, expected, ok := strings.Cut(asset.Digest, ":")
+	if ok && algorithm == "sha256" && hex.EncodeToString(hash.Sum(nil)) != expected {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("digest mismatch of %s", asset.Name)
+	}
+	return os.Rename(tmp, path)
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeFileAtomic writes to a temporary file first so an interrupted run never leaves a truncated file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	err := os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,53 @@
+package local
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TBXark/github-backup/provider/provider"
+)
+
+func TestLocal_SaveRelease(t *testing.T) {
+	root := t.TempDir()
+	l := NewLocal(&Config{Root: root})
+	content := "installer"
+	sum := sha256.Sum256([]byte(content))
+	release := &provider.Release{
+		TagName: "v1.0.0",
+		Raw:     []byte(`{"tag_name":"v1.0.0"}`),
+		Assets: []*provider.ReleaseAsset{
+			{Name: "setup.exe", Size: int64(len(content)), Digest: "sha256:" + hex.EncodeToString(sum[:])},
+		},
+	}
+	downloads := 0
+	download := func(asset *provider.ReleaseAsset) (io.ReadCloser, error) {
+		downloads++
+		return io.NopCloser(strings.NewReader(content)), nil
+	}
+	owner := &provider.Owner{Name: "tbxark"}
+	for i := 0; i < 2; i++ {
+		if err := l.SaveRelease(owner, "backup", release, download); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if downloads != 1 {
+		t.Errorf("expect 1 download but %d", downloads)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "tbxark", "backup.metadata", "releases", "v1.0.0", "setup.exe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("unexpected asset content %s", data)
+	}
+
+	release.Assets[0].Digest = "sha256:0000"
+	if err = l.SaveRelease(owner, "backup", release, download); err == nil {
+		t.Errorf("expect digest mismatch error")
+	}
+}
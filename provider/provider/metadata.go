@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type MetadataKind string
+
+const (
+	MetadataKindIssues         MetadataKind = "issues"
+	MetadataKindIssueComments  MetadataKind = "issue_comments"
+	MetadataKindPullRequests   MetadataKind = "pull_requests"
+	MetadataKindReviewComments MetadataKind = "review_comments"
+	MetadataKindLabels         MetadataKind = "labels"
+	MetadataKindMilestones     MetadataKind = "milestones"
+)
+
+const MetadataVersion = 1
+
+type Metadata struct {
+	Version int               `json:"version"`
+	Kind    MetadataKind      `json:"kind"`
+	Since   time.Time         `json:"since"`
+	Items   []json.RawMessage `json:"items"`
+}
+
+// Merge replaces items that share the same id and appends the rest.
+func (m *Metadata) Merge(items []json.RawMessage) error {
+	index := make(map[int64]int, len(m.Items))
+	for i, item := range m.Items {
+		id, err := metadataItemID(item)
+		if err != nil {
+			return err
+		}
+		index[id] = i
+	}
+	for _, item := range items {
+		id, err := metadataItemID(item)
+		if err != nil {
+			return err
+		}
+		if i, ok := index[id]; ok {
+			m.Items[i] = item
+			continue
+		}
+		index[id] = len(m.Items)
+		m.Items = append(m.Items, item)
+	}
+	return nil
+}
+
+func metadataItemID(item json.RawMessage) (int64, error) {
+	var v struct {
+		ID int64 `json:"id"`
+	}
+	err := json.Unmarshal(item, &v)
+	return v.ID, err
+}
+
+type MetadataSource interface {
+	ExportMetadata(repo *SourceRepo, token string, kind MetadataKind, since time.Time) ([]json.RawMessage, error)
+}
+
+type MetadataStore interface {
+	LoadMetadata(owner *Owner, repo string, kind MetadataKind) (*Metadata, error)
+	SaveMetadata(owner *Owner, repo string, metadata *Metadata) error
+}
@@ -0,0 +1,31 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMetadata_Merge(t *testing.T) {
+	m := &Metadata{
+		Items: []json.RawMessage{
+			json.RawMessage(`{"id":1,"title":"a"}`),
+			json.RawMessage(`{"id":2,"title":"b"}`),
+		},
+	}
+	err := m.Merge([]json.RawMessage{
+		json.RawMessage(`{"id":2,"title":"b2"}`),
+		json.RawMessage(`{"id":3,"title":"c"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`{"id":1,"title":"a"}`, `{"id":2,"title":"b2"}`, `{"id":3,"title":"c"}`}
+	if len(m.Items) != len(expected) {
+		t.Fatalf("expect %d items but %d", len(expected), len(m.Items))
+	}
+	for i, e := range expected {
+		if string(m.Items[i]) != e {
+			t.Errorf("item %d expect %s but %s", i, e, m.Items[i])
+		}
+	}
+}
@@ -1,14 +1,33 @@
 package provider
 
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
 type Owner struct {
 	Name  string
 	IsOrg bool
 }
 
 type Repo struct {
-	Name        string
-	Description string
-	AuthToken   string
+	Name         string
+	Description  string
+	AuthUsername string
+	AuthToken    string
+	CloneURL     string
+	SSHURL       string
+	Service      string
+	Gist         bool
+	Wiki         bool
+	Issues       bool
+	PullRequests bool
+	Labels       bool
+	Milestones   bool
+	Releases     bool
+	LFS          bool
 }
 
 type Provider interface {
@@ -16,3 +35,30 @@ type Provider interface {
 	MigrateRepo(from *Owner, to *Owner, repo *Repo) (string, error)
 	DeleteRepo(owner, repo string) (string, error)
 }
+
+// AuthURL embeds credentials into a http(s) url, other urls are returned unchanged.
+func AuthURL(rawURL, username, password string) string {
+	if password == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return rawURL
+	}
+	if username == "" {
+		username = "x-access-token"
+	}
+	u.User = url.UserPassword(username, password)
+	return u.String()
+}
+
+// PruneEmptyDirs removes dir and its parents up to root while they are empty,
+// namespaces of nested repo names like group/project are left behind by deletes otherwise.
+func PruneEmptyDirs(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package provider
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type ReleaseAsset struct {
+	Name        string
+	Size        int64
+	Digest      string
+	DownloadURL string
+}
+
+type Release struct {
+	TagName string
+	Raw     json.RawMessage
+	Assets  []*ReleaseAsset
+}
+
+type AssetDownloader func(asset *ReleaseAsset) (io.ReadCloser, error)
+
+type ReleaseSource interface {
+	ListReleases(repo *SourceRepo, token string) ([]*Release, error)
+	DownloadAsset(asset *ReleaseAsset, token string) (io.ReadCloser, error)
+}
+
+type ReleaseStore interface {
+	SaveRelease(owner *Owner, repo string, release *Release, download AssetDownloader) error
+}
@@ -0,0 +1,23 @@
+package provider
+
+type RepoInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Private     bool     `json:"private"`
+	Topics      []string `json:"topics"`
+}
+
+type RepoInfoStore interface {
+	LoadRepoInfo(owner *Owner, repo string) (*RepoInfo, error)
+	SaveRepoInfo(owner *Owner, info *RepoInfo) error
+}
+
+// Exporter is implemented by backup providers that can hand out a git url or path of a backed up repository.
+type Exporter interface {
+	ExportRepo(owner *Owner, repo string) (string, error)
+}
+
+// Restorer is implemented by sources that can recreate a repository, it returns the https clone url.
+type Restorer interface {
+	CreateRepo(owner *Owner, info *RepoInfo) (string, error)
+}
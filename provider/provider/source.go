@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/TBXark/github-backup/utils/request"
+)
+
+const GistNamePrefix = "gist-"
+
+type SourceRepo struct {
+	Name        string
+	FullName    string
+	Description string
+	Private     bool
+	Fork        bool
+	Archived    bool
+	HasWiki     bool
+	Topics      []string
+	Head        string
+	PushedAt    time.Time
+	CloneURL    string
+	SSHURL      string
+	Gist        bool
+}
+
+type Source interface {
+	Service() string
+	ListRepos(owner *Owner) ([]*SourceRepo, error)
+	Credentials(token string) (username string, password string)
+}
+
+// RateLimitedSource is implemented by sources whose api budget is tracked per token.
+type RateLimitedSource interface {
+	// RateLimitScope returns the api url and the modifiers of the requests made with token.
+	RateLimitScope(token string) (string, []request.Modifier)
+}
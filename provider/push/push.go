@@ -0,0 +1,131 @@
+package push
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/TBXark/github-backup/provider/provider"
+	"github.com/TBXark/github-backup/utils/git"
+)
+
+const mirrorSuffix = ".git"
+
+type Config struct {
+	Cache         string `json:"cache"`
+	URL           string `json:"url"`
+	CreateCommand string `json:"create_command"`
+	DeleteCommand string `json:"delete_command"`
+}
+
+var (
+	_ provider.Provider = &Push{}
+	_ provider.Exporter = &Push{}
+)
+
+type Push struct {
+	conf *Config
+}
+
+func NewPush(conf *Config) *Push {
+	return &Push{conf: conf}
+}
+
+func (p *Push) remoteURL(owner, repo string) string {
+	return strings.NewReplacer("{owner}", owner, "{repo}", repo).Replace(p.conf.URL)
+}
+
+func (p *Push) LoadRepos(owner *provider.Owner) ([]string, error) {
+	return loadRepos(filepath.Join(p.conf.Cache, owner.Name), "")
+}
+
+// loadRepos lists the mirrors in dir, other directories are namespaces of nested repo names.
+func loadRepos(dir, prefix string) ([]string, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]string, 0)
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			continue
+		}
+		if name, ok := strings.CutSuffix(dirEntry.Name(), mirrorSuffix); ok {
+			repos = append(repos, prefix+name)
+			continue
+		}
+		nested, nErr := loadRepos(filepath.Join(dir, dirEntry.Name()), prefix+dirEntry.Name()+"/")
+		if nErr != nil {
+			return nil, nErr
+		}
+		repos = append(repos, nested...)
+	}
+	return repos, nil
+}
+
+func (p *Push) MigrateRepo(from *provider.Owner, to *provider.Owner, repo *provider.Repo) (string, error) {
+	gitUrl := repo.SSHURL
+	if gitUrl == "" {
+		gitUrl = repo.CloneURL
+	}
+	mirrorPath := filepath.Join(p.conf.Cache, to.Name, repo.Name+mirrorSuffix)
+	if err := os.MkdirAll(filepath.Dir(mirrorPath), os.ModePerm); err != nil {
+		return "", err
+	}
+	_, err := os.Stat(mirrorPath)
+	created := os.IsNotExist(err)
+	if err = git.SyncMirror(gitUrl, mirrorPath); err != nil {
+		return "fail", err
+	}
+	remote := p.remoteURL(to.Name, repo.Name)
+	// the remote repository is created once, right after the local mirror is cloned
+	if created && p.conf.CreateCommand != "" {
+		if err = p.runHook(p.conf.CreateCommand, to.Name, repo.Name, remote, repo.Description); err != nil {
+			_ = os.RemoveAll(mirrorPath)
+			return "fail", err
+		}
+	}
+	log.Printf("push mirror %s", remote)
+	if err = git.Run(mirrorPath, "push", "--mirror", remote); err != nil {
+		return "fail", err
+	}
+	return "success", nil
+}
+
+func (p *Push) ExportRepo(owner *provider.Owner, repo string) (string, error) {
+	return p.remoteURL(owner.Name, repo), nil
+}
+
+func (p *Push) DeleteRepo(owner, repo string) (string, error) {
+	if p.conf.DeleteCommand != "" {
+		if err := p.runHook(p.conf.DeleteCommand, owner, repo, p.remoteURL(owner, repo), ""); err != nil {
+			return "fail", err
+		}
+	}
+	mirrorPath := filepath.Join(p.conf.Cache, owner, repo+mirrorSuffix)
+	err := os.RemoveAll(mirrorPath)
+	if err != nil {
+		return "fail", err
+	}
+	provider.PruneEmptyDirs(filepath.Dir(mirrorPath), filepath.Join(p.conf.Cache, owner))
+	return "success", nil
+}
+
+// runHook runs command with sh, names come from the remote api so they are only passed as environment variables
+func (p *Push) runHook(command, owner, repo, remote, description string) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"BACKUP_OWNER="+owner,
+		"BACKUP_REPO="+repo,
+		"BACKUP_URL="+remote,
+		"BACKUP_DESCRIPTION="+description,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("hook %q: %w: %s", command, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
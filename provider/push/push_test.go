@@ -0,0 +1,30 @@
+package push
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPush_RunHookQuoting(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	t.Setenv("HOOK_OUT", out)
+	repo := "repo;touch " + filepath.Join(dir, "semicolon") + "$(touch " + filepath.Join(dir, "subshell") + ")"
+	p := NewPush(&Config{})
+	if err := p.runHook(`: {repo}; printf '%s' "$BACKUP_REPO" > "$HOOK_OUT"`, "tbxark", repo, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != repo {
+		t.Errorf("expected %q but got %q", repo, data)
+	}
+	for _, name := range []string{"semicolon", "subshell"} {
+		if _, err = os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("repo name was run as a command, %s exists", name)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TBXark/github-backup/utils/request"
+)
+
+const (
+	emptyPayloadHash    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayloadHash = "UNSIGNED-PAYLOAD"
+)
+
+type client struct {
+	endpoint        *url.URL
+	region          string
+	bucket          string
+	pathStyle       bool
+	accessKeyID     string
+	secretAccessKey string
+}
+
+func (c *client) objectURL(key string, query map[string]string) *url.URL {
+	u := *c.endpoint
+	path := "/" + key
+	if c.pathStyle {
+		path = "/" + c.bucket + path
+	} else {
+		u.Host = c.bucket + "." + u.Host
+	}
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+func (c *client) do(method, key string, query map[string]string, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.objectURL(key, query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		req.ContentLength = size
+		payloadHash = unsignedPayloadHash
+	}
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	c.sign(req, time.Now())
+	httpClient := request.DefaultHttpClient()
+	if body != nil {
+		// uploads of large archives can take much longer than the default timeout
+		httpClient.Timeout = 0
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		var e struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		_ = xml.NewDecoder(resp.Body).Decode(&e)
+		return nil, fmt.Errorf("s3 %s %s: %s %s %s", method, key, resp.Status, e.Code, e.Message)
+	}
+	return resp, nil
+}
+
+func (c *client) putObject(key string, header http.Header, body io.Reader, size int64) error {
+	resp, err := c.do("PUT", key, nil, header, body, size)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *client) deleteObject(key string) error {
+	resp, err := c.do("DELETE", key, nil, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *client) tagObject(key string, tags map[string]string) error {
+	tagging := taggingBody{}
+	for k, v := range tags {
+		tagging.TagSet = append(tagging.TagSet, tag{Key: k, Value: v})
+	}
+	body, err := xml.Marshal(tagging)
+	if err != nil {
+		return err
+	}
+	sum := md5.Sum(body)
+	header := http.Header{}
+	header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	resp, err := c.do("PUT", key, map[string]string{"tagging": ""}, header, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// listObjects returns the keys and common prefixes under prefix, following continuation tokens.
+func (c *client) listObjects(prefix, delimiter string) ([]string, []string, error) {
+	keys := make([]string, 0)
+	prefixes := make([]string, 0)
+	token := ""
+	for {
+		query := map[string]string{"list-type": "2", "prefix": prefix}
+		if delimiter != "" {
+			query["delimiter"] = delimiter
+		}
+		if token != "" {
+			query["continuation-token"] = token
+		}
+		resp, err := c.do("GET", "", query, nil, nil, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, content := range result.Contents {
+			keys = append(keys, content.Key)
+		}
+		for _, p := range result.CommonPrefixes {
+			prefixes = append(prefixes, p.Prefix)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	return keys, prefixes, nil
+}
+
+// sign adds an AWS Signature Version 4 authorization header, every header already set on the request is signed.
+func (c *client) sign(req *http.Request, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("x-amz-content-sha256"),
+	}, "\n")
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+c.secretAccessKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func canonicalQuery(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, uriEncode(k, true)+"="+uriEncode(query[k], true))
+	}
+	return strings.Join(parts, "&")
+}
+
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || (ch == '/' && !encodeSlash) {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type taggingBody struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  []tag    `xml:"TagSet>Tag"`
+}
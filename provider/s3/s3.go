@@ -0,0 +1,287 @@
+package s3
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TBXark/github-backup/provider/provider"
+	"github.com/TBXark/github-backup/utils/git"
+)
+
+type Format string
+
+const (
+	FormatBundle  Format = "bundle"
+	FormatTarball Format = "tar.gz"
+)
+
+type DeleteAction string
+
+const (
+	DeleteActionDelete DeleteAction = "delete"
+	DeleteActionTag    DeleteAction = "tag"
+)
+
+const timeLayout = "20060102T150405Z"
+
+type Config struct {
+	Endpoint             string            `json:"endpoint"`
+	Region               string            `json:"region"`
+	Bucket               string            `json:"bucket"`
+	PathStyle            bool              `json:"path_style"`
+	AccessKeyID          string            `json:"access_key_id"`
+	SecretAccessKey      string            `json:"secret_access_key"`
+	Prefix               string            `json:"prefix"`
+	StorageClass         string            `json:"storage_class"`
+	ServerSideEncryption string            `json:"server_side_encryption"`
+	KMSKeyID             string            `json:"kms_key_id"`
+	Format               Format            `json:"format"`
+	Cache                string            `json:"cache"`
+	DeleteAction         DeleteAction      `json:"delete_action"`
+	DeleteTags           map[string]string `json:"delete_tags"`
+}
+
+var _ provider.Provider = &S3{}
+
+type S3 struct {
+	conf   *Config
+	client *client
+}
+
+func NewS3(conf *Config) (*S3, error) {
+	if conf.Region == "" {
+		conf.Region = "us-east-1"
+	}
+	if conf.Endpoint == "" {
+		conf.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", conf.Region)
+	}
+	endpoint, err := url.Parse(strings.TrimRight(conf.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	conf.Prefix = strings.Trim(conf.Prefix, "/")
+	if conf.Format == "" {
+		conf.Format = FormatBundle
+	}
+	if conf.Format != FormatBundle && conf.Format != FormatTarball {
+		return nil, fmt.Errorf("unsupported s3 format: %s", conf.Format)
+	}
+	if conf.Cache == "" {
+		conf.Cache = filepath.Join(os.TempDir(), "github-backup", "s3", conf.Bucket)
+	}
+	if conf.DeleteAction == "" {
+		conf.DeleteAction = DeleteActionDelete
+	}
+	if len(conf.DeleteTags) == 0 {
+		conf.DeleteTags = map[string]string{"github-backup": "deleted"}
+	}
+	return &S3{
+		conf: conf,
+		client: &client{
+			endpoint:        endpoint,
+			region:          conf.Region,
+			bucket:          conf.Bucket,
+			pathStyle:       conf.PathStyle,
+			accessKeyID:     conf.AccessKeyID,
+			secretAccessKey: conf.SecretAccessKey,
+		},
+	}, nil
+}
+
+func (s *S3) key(parts ...string) string {
+	if s.conf.Prefix != "" {
+		parts = append([]string{s.conf.Prefix}, parts...)
+	}
+	return path.Join(parts...)
+}
+
+func (s *S3) LoadRepos(owner *provider.Owner) ([]string, error) {
+	ownerPrefix := s.key(owner.Name) + "/"
+	_, prefixes, err := s.client.listObjects(ownerPrefix, "/")
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		repos = append(repos, strings.TrimSuffix(strings.TrimPrefix(p, ownerPrefix), "/"))
+	}
+	return repos, nil
+}
+
+func (s *S3) MigrateRepo(from *provider.Owner, to *provider.Owner, repo *provider.Repo) (string, error) {
+	gitUrl := repo.SSHURL
+	if gitUrl == "" {
+		gitUrl = repo.CloneURL
+	}
+	cachePath := filepath.Join(s.conf.Cache, to.Name, repo.Name)
+	mirrorPath := cachePath + ".git"
+	refsPath := cachePath + ".refs.json"
+	if err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm); err != nil {
+		return "", err
+	}
+	if err := git.SyncMirror(gitUrl, mirrorPath); err != nil {
+		return "fail", err
+	}
+	refs, err := git.Refs(mirrorPath)
+	if err != nil {
+		return "fail", err
+	}
+	if len(refs) == 0 {
+		return "empty", nil
+	}
+	if uploaded, rErr := loadRefs(refsPath); rErr == nil && git.SameRefs(refs, uploaded) {
+		return "unchanged", nil
+	}
+
+	name := fmt.Sprintf("%s-%s.%s", path.Base(repo.Name), time.Now().UTC().Format(timeLayout), s.conf.Format)
+	archive := cachePath + "." + string(s.conf.Format) + ".tmp"
+	defer func() {
+		_ = os.Remove(archive)
+	}()
+	if s.conf.Format == FormatBundle {
+		err = git.BundleCreate(mirrorPath, archive)
+	} else {
+		err = createTarball(mirrorPath, path.Base(repo.Name)+".git", archive)
+	}
+	if err != nil {
+		return "fail", err
+	}
+	key := s.key(to.Name, repo.Name, name)
+	log.Printf("uploading %s", key)
+	if err = s.upload(key, archive); err != nil {
+		return "fail", err
+	}
+	if err = saveRefs(refsPath, refs); err != nil {
+		return "fail", err
+	}
+	return "success", nil
+}
+
+func (s *S3) upload(key, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	if s.conf.StorageClass != "" {
+		header.Set("x-amz-storage-class", s.conf.StorageClass)
+	}
+	if s.conf.ServerSideEncryption != "" {
+		header.Set("x-amz-server-side-encryption", s.conf.ServerSideEncryption)
+	}
+	if s.conf.KMSKeyID != "" {
+		header.Set("x-amz-server-side-encryption-aws-kms-key-id", s.conf.KMSKeyID)
+	}
+	return s.client.putObject(key, header, f, info.Size())
+}
+
+func (s *S3) DeleteRepo(owner, repo string) (string, error) {
+	keys, _, err := s.client.listObjects(s.key(owner, repo)+"/", "")
+	if err != nil {
+		return "fail", err
+	}
+	for _, key := range keys {
+		if s.conf.DeleteAction == DeleteActionTag {
+			err = s.client.tagObject(key, s.conf.DeleteTags)
+		} else {
+			err = s.client.deleteObject(key)
+		}
+		if err != nil {
+			return "fail", err
+		}
+	}
+	cachePath := filepath.Join(s.conf.Cache, owner, repo)
+	_ = os.RemoveAll(cachePath + ".git")
+	_ = os.Remove(cachePath + ".refs.json")
+	if s.conf.DeleteAction == DeleteActionTag {
+		return "tagged", nil
+	}
+	return "success", nil
+}
+
+func createTarball(dir, root, file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, wErr error) error {
+		if wErr != nil {
+			return wErr
+		}
+		info, iErr := d.Info()
+		if iErr != nil {
+			return iErr
+		}
+		rel, rErr := filepath.Rel(dir, p)
+		if rErr != nil {
+			return rErr
+		}
+		header, hErr := tar.FileInfoHeader(info, "")
+		if hErr != nil {
+			return hErr
+		}
+		header.Name = path.Join(root, filepath.ToSlash(rel))
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if hErr = tw.WriteHeader(header); hErr != nil {
+			return hErr
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		src, oErr := os.Open(p)
+		if oErr != nil {
+			return oErr
+		}
+		_, cErr := io.Copy(tw, src)
+		_ = src.Close()
+		return cErr
+	})
+	for _, closer := range []io.Closer{tw, gz, f} {
+		if cErr := closer.Close(); err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+func loadRefs(file string) (map[string]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]string)
+	err = json.Unmarshal(data, &refs)
+	return refs, err
+}
+
+func saveRefs(file string, refs map[string]string) error {
+	data, err := json.Marshal(refs)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0o644)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/TBXark/github-backup/config"
+	"github.com/TBXark/github-backup/provider/provider"
+	"github.com/TBXark/github-backup/utils/git"
+)
+
+type restoreOptions struct {
+	owner  string
+	backup string
+	repo   *regexp.Regexp
+	to     string
+	toOrg  bool
+	force  bool
+}
+
+func runRestore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	conf := flags.String("config", "config.json", "config file")
+	owner := flags.String("owner", "", "owner of the target to restore, defaults to the first target")
+	backup := flags.String("backup", "", "name of the backup destination to restore from, defaults to the first one")
+	repo := flags.String("repo", ".*", "regex of the repos to restore")
+	to := flags.String("to", "", "owner to restore into, defaults to the target owner")
+	toOrg := flags.Bool("to-org", false, "the owner to restore into is an organization")
+	force := flags.Bool("force", false, "push into repos that already have refs and overwrite diverged branches and tags")
+	_ = flags.Parse(args)
+
+	pattern, err := regexp.Compile(*repo)
+	if err != nil {
+		log.Fatalf("invalid repo pattern: %s", err.Error())
+	}
+	data, err := LoadConfig(*conf)
+	if err != nil {
+		log.Fatalf("load config error: %s", err.Error())
+	}
+	failed := restore(data, &restoreOptions{
+		owner:  *owner,
+		backup: *backup,
+		repo:   pattern,
+		to:     *to,
+		toOrg:  *toOrg,
+		force:  *force,
+	})
+	if failed > 0 {
+		log.Fatalf("restore finished with %d failures", failed)
+	}
+}
+
+func findTarget(conf *config.SyncConfig, owner string) (*config.GithubConfig, error) {
+	for _, target := range conf.Targets {
+		if owner == "" || target.Owner == owner {
+			target.MergeDefault(conf.DefaultConf)
+			return target, nil
+		}
+	}
+	return nil, fmt.Errorf("target %q not found", owner)
+}
+
+func findDestination(target *config.GithubConfig, name string) (*config.BackupProviderConfig, error) {
+	names, err := target.DestinationNames()
+	if err != nil {
+		return nil, err
+	}
+	for i, dest := range target.Destinations() {
+		if name == "" || names[i] == name {
+			return dest, nil
+		}
+	}
+	return nil, fmt.Errorf("backup destination %q not found", name)
+}
+
+func restore(conf *config.SyncConfig, opts *restoreOptions) int {
+	target, err := findTarget(conf, opts.owner)
+	if err != nil {
+		log.Fatalf("%s", err.Error())
+	}
+	dest, err := findDestination(target, opts.backup)
+	if err != nil {
+		log.Fatalf("%s", err.Error())
+	}
+	backup, err := BuildBackupProvider(dest)
+	if err != nil {
+		log.Fatalf("build backup provider error: %s", err.Error())
+	}
+	exporter, ok := backup.(provider.Exporter)
+	if !ok {
+		log.Fatalf("backup provider %s does not support restore", dest.Type)
+	}
+	source, err := BuildSourceProvider(target.Source, target.Token)
+	if err != nil {
+		log.Fatalf("build source provider error: %s", err.Error())
+	}
+	restorer, ok := source.(provider.Restorer)
+	if !ok {
+		log.Fatalf("source provider %s does not support restore", source.Service())
+	}
+
+	from := &provider.Owner{
+		Name:  target.RepoOwner,
+		IsOrg: target.IsRepoOwnerOrg,
+	}
+	to := &provider.Owner{
+		Name:  target.Owner,
+		IsOrg: target.IsOwnerOrg,
+	}
+	if opts.to != "" {
+		to = &provider.Owner{
+			Name:  opts.to,
+			IsOrg: opts.toOrg,
+		}
+	}
+
+	repos, err := backup.LoadRepos(from)
+	if err != nil {
+		log.Fatalf("load %s repos error: %s", from.Name, err.Error())
+	}
+	username, password := source.Credentials(sourceToken(target))
+
+	failed := 0
+	for _, repo := range repos {
+		if !opts.repo.MatchString(repo) {
+			continue
+		}
+		s, e := restoreRepo(exporter, restorer, backup, from, to, repo, username, password, opts.force)
+		if e != nil {
+			failed++
+			log.Printf("restore %s to %s error: %s", repo, to.Name, e.Error())
+		} else {
+			log.Printf("restore %s to %s %s", repo, to.Name, s)
+		}
+	}
+	return failed
+}
+
+func restoreRepo(exporter provider.Exporter, restorer provider.Restorer, backup provider.Provider, from, to *provider.Owner, repo, username, password string, force bool) (string, error) {
+	location, err := exporter.ExportRepo(from, repo)
+	if err != nil {
+		return "", err
+	}
+
+	// repos backed up without metadata are restored as private repos
+	info := &provider.RepoInfo{Name: repo, Private: true}
+	if store, ok := backup.(provider.RepoInfoStore); ok {
+		if saved, lErr := store.LoadRepoInfo(from, repo); lErr == nil {
+			info = saved
+			info.Name = repo
+		}
+	}
+
+	cloneURL, err := restorer.CreateRepo(to, info)
+	if err != nil {
+		return "", err
+	}
+	pushURL := provider.AuthURL(cloneURL, username, password)
+
+	// CreateRepo returns repos that already exist, never rewrite a live repository by accident
+	remoteRefs, err := git.LsRemote(pushURL)
+	if err != nil {
+		return "", err
+	}
+	if len(remoteRefs) > 0 && !force {
+		return "", fmt.Errorf("%s/%s already has %d refs, use -force to push into it", to.Name, repo, len(remoteRefs))
+	}
+
+	dir, err := os.MkdirTemp("", "github-backup-restore-")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	mirror := filepath.Join(dir, repo+".git")
+	err = git.CloneMirror(location, mirror)
+	if err != nil {
+		return "", err
+	}
+	refs, err := git.Refs(mirror)
+	if err != nil {
+		return "", err
+	}
+
+	// without force git rejects updates that are not fast-forwards
+	forced := ""
+	if force {
+		forced = "+"
+	}
+	prefix := branchPrefix(refs)
+	refspecs := make([]string, 0, len(refs))
+	for ref := range refs {
+		switch {
+		case strings.HasPrefix(ref, "refs/tags/"):
+			refspecs = append(refspecs, forced+ref+":"+ref)
+		case strings.HasPrefix(ref, prefix) && ref != prefix+"HEAD":
+			refspecs = append(refspecs, forced+ref+":refs/heads/"+strings.TrimPrefix(ref, prefix))
+		}
+	}
+	if len(refspecs) == 0 {
+		return "empty", nil
+	}
+	err = git.Run(mirror, append([]string{"push", pushURL}, refspecs...)...)
+	if err != nil {
+		return "", err
+	}
+	return "success", nil
+}
+
+// branchPrefix returns where the branches of a backup live, working copy backups keep them as remote tracking refs.
+func branchPrefix(refs map[string]string) string {
+	for ref := range refs {
+		if strings.HasPrefix(ref, "refs/remotes/origin/") {
+			return "refs/remotes/origin/"
+		}
+	}
+	return "refs/heads/"
+}
@@ -0,0 +1,175 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const Version = 1
+
+// errLocked is returned by tryLock when another process holds the lock.
+var errLocked = errors.New("locked")
+
+// Repo is the sync state of one repository in one backup destination.
+type Repo struct {
+	LastSeen     time.Time `json:"last_seen"`
+	LastSuccess  time.Time `json:"last_success"`
+	LastError    string    `json:"last_error,omitempty"`
+	LastErrorAt  time.Time `json:"last_error_at"`
+	Head         string    `json:"head,omitempty"`
+	PushedAt     time.Time `json:"pushed_at"`
+	DeleteChecks int       `json:"delete_checks,omitempty"`
+}
+
+type file struct {
+	Version int              `json:"version"`
+	Repos   map[string]*Repo `json:"repos"`
+}
+
+// Store keeps the state of every repository, it is only kept in memory when path is empty.
+type Store struct {
+	path     string
+	lock     sync.Mutex
+	repos    map[string]*Repo
+	lockFile *os.File
+}
+
+func NewStore(path string) *Store {
+	return &Store{
+		path:  path,
+		repos: make(map[string]*Repo),
+	}
+}
+
+// Load replaces the in memory state with the state file, a missing file is an empty state.
+func (s *Store) Load() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	f := &file{}
+	err = json.Unmarshal(data, f)
+	if err != nil {
+		return err
+	}
+	if f.Version != Version {
+		return fmt.Errorf("unsupported state version %d of %s", f.Version, s.path)
+	}
+	if f.Repos == nil {
+		f.Repos = make(map[string]*Repo)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.repos = f.Repos
+	return nil
+}
+
+// Lock takes an exclusive lock of a file next to the state file for the duration of a run, it fails while another
+// run holds it so two runs never overwrite the state of each other. The lock belongs to the open file, so the system
+// releases it when a run crashes and the file left behind does not block the next run.
+func (s *Store) Lock() error {
+	if s.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.lockPath(), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if err = tryLock(f); err != nil {
+		_ = f.Close()
+		if errors.Is(err, errLocked) {
+			if pid, _ := os.ReadFile(s.lockPath()); len(pid) > 0 {
+				return fmt.Errorf("state file %s is locked by process %s", s.path, strings.TrimSpace(string(pid)))
+			}
+			return fmt.Errorf("state file %s is locked by another run", s.path)
+		}
+		return err
+	}
+	// the pid is only informational, it names the holder in the error of other runs
+	if err = f.Truncate(0); err == nil {
+		_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+	}
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.lockFile = f
+	return nil
+}
+
+// Unlock releases the lock taken by Lock, the lock file is kept since removing it could race with a run locking it.
+func (s *Store) Unlock() error {
+	if s.lockFile == nil {
+		return nil
+	}
+	err := s.lockFile.Close()
+	s.lockFile = nil
+	return err
+}
+
+func (s *Store) lockPath() string {
+	return s.path + ".lock"
+}
+
+func (s *Store) Save() error {
+	if s.path == "" {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	data, err := json.MarshalIndent(&file{Version: Version, Repos: s.repos}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Get returns a copy of the state of key, the zero value when it is unknown.
+func (s *Store) Get(key string) Repo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if repo, ok := s.repos[key]; ok {
+		return *repo
+	}
+	return Repo{}
+}
+
+// Update changes the state of key in place, the state is created when it is unknown.
+func (s *Store) Update(key string, update func(repo *Repo)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	repo, ok := s.repos[key]
+	if !ok {
+		repo = &Repo{}
+		s.repos[key] = repo
+	}
+	update(repo)
+}
+
+func (s *Store) Delete(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.repos, key)
+}
@@ -0,0 +1,101 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+	store := NewStore(path)
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	store.Update("owner/local/repo", func(repo *Repo) {
+		repo.LastSuccess = now
+		repo.Head = "abc"
+	})
+	store.Update("owner/local/gone", func(repo *Repo) {
+		repo.DeleteChecks++
+	})
+	store.Update("owner/local/gone", func(repo *Repo) {
+		repo.DeleteChecks++
+	})
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewStore(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	repo := loaded.Get("owner/local/repo")
+	if !repo.LastSuccess.Equal(now) || repo.Head != "abc" {
+		t.Fatalf("unexpected repo state %+v", repo)
+	}
+	if checks := loaded.Get("owner/local/gone").DeleteChecks; checks != 2 {
+		t.Fatalf("expected 2 delete checks, got %d", checks)
+	}
+	loaded.Delete("owner/local/gone")
+	if checks := loaded.Get("owner/local/gone").DeleteChecks; checks != 0 {
+		t.Fatalf("expected deleted state, got %d delete checks", checks)
+	}
+}
+
+func TestStore_Version(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "repos": {}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewStore(path).Load(); err == nil {
+		t.Fatal("expected unsupported version error")
+	}
+}
+
+func TestStore_Memory(t *testing.T) {
+	store := NewStore("")
+	store.Update("repo", func(repo *Repo) {
+		repo.LastError = "failed"
+	})
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if store.Get("repo").LastError != "failed" {
+		t.Fatal("expected in memory state")
+	}
+}
+
+func TestStore_Lock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store := NewStore(path)
+	if err := store.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewStore(path).Lock(); err == nil {
+		t.Fatal("expected a second lock of the same state file to fail")
+	}
+	if err := store.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewStore(path).Lock(); err != nil {
+		t.Fatalf("expected the lock to be free again, got %v", err)
+	}
+}
+
+func TestStore_LockLeftBehind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	// a crashed run leaves the lock file with its pid behind
+	if err := os.WriteFile(path+".lock", []byte("999999\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(path)
+	if err := store.Lock(); err != nil {
+		t.Fatalf("expected a lock file without holder to be taken, got %v", err)
+	}
+	if err := store.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,13 +3,24 @@ package main
 import (
 	"fmt"
 	"log"
+	"regexp"
+	"sync"
+	"time"
 
 	"github.com/TBXark/github-backup/config"
+	"github.com/TBXark/github-backup/provider/bitbucket"
+	"github.com/TBXark/github-backup/provider/bundle"
 	"github.com/TBXark/github-backup/provider/gitea"
 	"github.com/TBXark/github-backup/provider/github"
+	"github.com/TBXark/github-backup/provider/gitlab"
 	"github.com/TBXark/github-backup/provider/local"
 	"github.com/TBXark/github-backup/provider/provider"
+	"github.com/TBXark/github-backup/provider/push"
+	"github.com/TBXark/github-backup/provider/s3"
+	"github.com/TBXark/github-backup/state"
 	"github.com/TBXark/github-backup/utils/matcher"
+	"github.com/TBXark/github-backup/utils/request"
+	"github.com/TBXark/github-backup/utils/retry"
 )
 
 func BuildBackupProvider(conf *config.BackupProviderConfig) (provider.Provider, error) {
@@ -26,47 +37,363 @@ func BuildBackupProvider(conf *config.BackupProviderConfig) (provider.Provider,
 			return nil, err
 		}
 		return local.NewLocal(c), nil
+	case config.BackupProviderConfigTypeBundle:
+		c, err := config.Convert[bundle.Config](conf.Config)
+		if err != nil {
+			return nil, err
+		}
+		return bundle.NewBundle(c), nil
+	case config.BackupProviderConfigTypeS3:
+		c, err := config.Convert[s3.Config](conf.Config)
+		if err != nil {
+			return nil, err
+		}
+		return s3.NewS3(c)
+	case config.BackupProviderConfigTypeGitlab:
+		c, err := config.Convert[gitlab.Config](conf.Config)
+		if err != nil {
+			return nil, err
+		}
+		return gitlab.NewGitlab(c), nil
+	case config.BackupProviderConfigTypePush:
+		c, err := config.Convert[push.Config](conf.Config)
+		if err != nil {
+			return nil, err
+		}
+		return push.NewPush(c), nil
 	}
 	return nil, fmt.Errorf("unknown backup provider type: %s", conf.Type)
 }
 
+func BuildSourceProvider(conf *config.SourceProviderConfig, token string) (provider.Source, error) {
+	if conf == nil {
+		return github.NewGithub(&github.Config{Token: token}), nil
+	}
+	switch conf.Type {
+	case config.SourceProviderConfigTypeGithub, "":
+		c := &github.Config{}
+		if len(conf.Config) > 0 {
+			var err error
+			c, err = config.Convert[github.Config](conf.Config)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if c.Token == "" {
+			c.Token = token
+		}
+		return github.NewGithub(c), nil
+	case config.SourceProviderConfigTypeGitlab:
+		c, err := config.Convert[gitlab.Config](conf.Config)
+		if err != nil {
+			return nil, err
+		}
+		if c.Token == "" {
+			c.Token = token
+		}
+		return gitlab.NewGitlab(c), nil
+	case config.SourceProviderConfigTypeGitea:
+		c, err := config.Convert[gitea.Config](conf.Config)
+		if err != nil {
+			return nil, err
+		}
+		if c.Token == "" {
+			c.Token = token
+		}
+		return gitea.NewGitea(c), nil
+	case config.SourceProviderConfigTypeBitbucket:
+		c, err := config.Convert[bitbucket.Config](conf.Config)
+		if err != nil {
+			return nil, err
+		}
+		if c.Token == "" {
+			c.Token = token
+		}
+		return bitbucket.NewBitbucket(c), nil
+	}
+	return nil, fmt.Errorf("unknown source provider type: %s", conf.Type)
+}
+
+// LoadConfig loads the config at path and merges the defaults into every target. Targets whose source names repos
+// with a path are rejected here when a destination can only store flat names, rather than failing on every repo.
+func LoadConfig(path string) (*config.SyncConfig, error) {
+	conf, err := config.NewConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, target := range conf.Targets {
+		target.MergeDefault(conf.DefaultConf)
+		nested, nErr := nestedRepoNames(target.Source)
+		if nErr != nil {
+			return nil, fmt.Errorf("target %s: %w", target.Owner, nErr)
+		}
+		if nested == "" {
+			continue
+		}
+		for _, dest := range target.Destinations() {
+			switch dest.Type {
+			case config.BackupProviderConfigTypeLocal, config.BackupProviderConfigTypeBundle, config.BackupProviderConfigTypePush:
+			default:
+				return nil, fmt.Errorf("target %s: %s is not supported by backup type %s, it only stores flat repo names", target.Owner, nested, dest.Type)
+			}
+		}
+	}
+	return conf, nil
+}
+
+// nestedRepoNames returns the option of the source that names repos like group/project, or empty when names are flat.
+func nestedRepoNames(conf *config.SourceProviderConfig) (string, error) {
+	if conf == nil {
+		return "", nil
+	}
+	switch conf.Type {
+	case config.SourceProviderConfigTypeGithub, "":
+		if len(conf.Config) == 0 {
+			return "", nil
+		}
+		c, err := config.Convert[github.Config](conf.Config)
+		if err != nil {
+			return "", err
+		}
+		if c.Mode == github.ModeStarred && c.StarredNaming == github.StarredNamingPath {
+			return "starred_naming path", nil
+		}
+	case config.SourceProviderConfigTypeGitlab:
+		c, err := config.Convert[gitlab.Config](conf.Config)
+		if err != nil {
+			return "", err
+		}
+		if c.SubgroupPath == gitlab.SubgroupPathPreserve {
+			return "subgroup_path preserve", nil
+		}
+	}
+	return "", nil
+}
+
+// BuildRetryPolicy fills the unset fields of conf with the default policy.
+func BuildRetryPolicy(conf *config.RetryConfig) (*retry.Policy, error) {
+	policy := retry.DefaultPolicy()
+	if conf == nil {
+		return policy, nil
+	}
+	if conf.MaxAttempts > 0 {
+		policy.MaxAttempts = conf.MaxAttempts
+	}
+	if conf.InitialBackoff != "" {
+		d, err := time.ParseDuration(conf.InitialBackoff)
+		if err != nil {
+			return nil, err
+		}
+		policy.InitialBackoff = d
+	}
+	if conf.MaxBackoff != "" {
+		d, err := time.ParseDuration(conf.MaxBackoff)
+		if err != nil {
+			return nil, err
+		}
+		policy.MaxBackoff = d
+	}
+	if conf.Jitter != nil {
+		policy.Jitter = *conf.Jitter
+	}
+	if len(conf.StatusCodes) > 0 {
+		policy.StatusCodes = conf.StatusCodes
+	}
+	if len(conf.GitErrorPatterns) > 0 {
+		policy.GitErrorPatterns = make([]*regexp.Regexp, 0, len(conf.GitErrorPatterns))
+		for _, p := range conf.GitErrorPatterns {
+			r, err := regexp.Compile(p)
+			if err != nil {
+				return nil, err
+			}
+			policy.GitErrorPatterns = append(policy.GitErrorPatterns, r)
+		}
+	}
+	return policy, nil
+}
+
 type SyncTask struct {
-	conf    *config.SyncConfig
-	counter map[string]int
+	conf  *config.SyncConfig
+	state *state.Store
+	// slots bounds the number of repos processed at the same time across all targets
+	slots chan struct{}
+	// dryRun reports what would be migrated or deleted without touching any backup
+	dryRun bool
+	// fullRefresh is how long an unchanged repo may go without being migrated in incremental mode
+	fullRefresh time.Duration
 }
 
-func NewTask(conf *config.SyncConfig) *SyncTask {
+const defaultFullRefreshInterval = 7 * 24 * time.Hour
+
+func NewTask(conf *config.SyncConfig, dryRun bool) *SyncTask {
+	fullRefresh := defaultFullRefreshInterval
+	if conf.FullRefreshInterval != "" {
+		d, err := time.ParseDuration(conf.FullRefreshInterval)
+		if err != nil {
+			log.Printf("invalid full refresh interval %s, using %s: %s", conf.FullRefreshInterval, fullRefresh, err.Error())
+		} else {
+			fullRefresh = d
+		}
+	}
 	return &SyncTask{
-		conf:    conf,
-		counter: make(map[string]int, 100),
+		conf:        conf,
+		state:       state.NewStore(conf.StateFile),
+		slots:       make(chan struct{}, max(conf.Concurrency, 1)),
+		dryRun:      dryRun,
+		fullRefresh: fullRefresh,
 	}
 }
 
+// Run implements cron.Job, a scheduled run that can not start is logged and retried by the next schedule.
 func (t *SyncTask) Run() {
+	if err := t.RunOnce(); err != nil {
+		log.Printf("run error: %s", err.Error())
+	}
+}
+
+// RunOnce backs up every target, it fails when the state can not be locked or loaded.
+func (t *SyncTask) RunOnce() error {
+	// dry runs never save the state, only runs that write it take the lock
+	if !t.dryRun {
+		if err := t.state.Lock(); err != nil {
+			return fmt.Errorf("lock state error: %w", err)
+		}
+		defer func() {
+			if err := t.state.Unlock(); err != nil {
+				log.Printf("unlock state error: %s", err.Error())
+			}
+		}()
+	}
+	// reload the state every run, one-shot runs may have changed it since the last scheduled run
+	if err := t.state.Load(); err != nil {
+		return fmt.Errorf("load state error: %w", err)
+	}
+	// merge default config before any target starts, targets may share default values
 	for _, target := range t.conf.Targets {
-		t.execute(target)
+		target.MergeDefault(t.conf.DefaultConf)
+	}
+	// targets are started with the same limit as repos, the slots still bound the work across them
+	targets := make(chan struct{}, cap(t.slots))
+	var wg sync.WaitGroup
+	for _, target := range t.conf.Targets {
+		wg.Add(1)
+		targets <- struct{}{}
+		go func() {
+			defer func() {
+				<-targets
+				wg.Done()
+			}()
+			if err := t.execute(target); err != nil {
+				log.Printf("backup %s error: %s", target.Owner, err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+	t.saveState()
+	for _, limit := range request.DefaultRateLimiter.Budgets() {
+		log.Printf("rate limit of %s %s: %d/%d remaining, reset at %s",
+			limit.Host, limit.Resource, limit.Remaining, limit.Limit, limit.Reset.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (t *SyncTask) saveState() {
+	if t.dryRun {
+		return
+	}
+	if err := t.state.Save(); err != nil {
+		log.Printf("save state error: %s", err.Error())
 	}
 }
 
-func (t *SyncTask) execute(target *config.GithubConfig) {
-	// merge default config
-	target.MergeDefault(t.conf.DefaultConf)
+func (t *SyncTask) execute(target *config.GithubConfig) (err error) {
+	// a panic in one target must not take down the other targets
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	// build source provider
+	source, err := BuildSourceProvider(target.Source, target.Token)
+	if err != nil {
+		return fmt.Errorf("build source provider error: %w", err)
+	}
+
+	policy, err := BuildRetryPolicy(target.Retry)
+	if err != nil {
+		return fmt.Errorf("build retry policy error: %w", err)
+	}
+
+	from := &provider.Owner{
+		Name:  target.Owner,
+		IsOrg: target.IsOwnerOrg,
+	}
 
-	// load all github repos
-	loader := github.NewGithub(target.Token)
-	repos, err := loader.LoadAllRepos(target.Owner, target.IsOwnerOrg)
+	// load all source repos once, they are shared by every destination
+	repos, err := retry.DoValue(policy, "load "+target.Owner+" repos", func() ([]*provider.SourceRepo, error) {
+		return source.ListRepos(from)
+	})
 	if err != nil {
-		log.Panicf("load %s repos error: %s", target.RepoOwner, err.Error())
+		return fmt.Errorf("load %s repos error: %w", target.Owner, err)
 	}
+	log.Printf("found %d repos in %s", len(repos), target.Owner)
+
+	names, err := target.DestinationNames()
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for i, dest := range target.Destinations() {
+		name := names[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := t.backup(target, source, policy, repos, dest, name)
+			t.saveState()
+			if t.dryRun {
+				log.Printf("[dry-run] plan %s to %s: %d create, %d update, %d unchanged, %d skip, %d delete, %d pending delete",
+					target.Owner, name, result.created, result.updated, result.unchanged, result.skipped, result.deleted, result.pendingDelete)
+				return
+			}
+			log.Printf("backup %s to %s finished: %d success, %d unchanged, %d failed, %d skipped, %d deleted, %d delete failed, %d pending delete",
+				target.Owner, name, result.success, result.unchanged, result.failed, result.skipped, result.deleted, result.deleteFailed, result.pendingDelete)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+type backupResult struct {
+	created       int
+	updated       int
+	unchanged     int
+	success       int
+	failed        int
+	skipped       int
+	deleted       int
+	deleteFailed  int
+	pendingDelete int
+}
+
+func (t *SyncTask) backup(target *config.GithubConfig, source provider.Source, policy *retry.Policy, repos []*provider.SourceRepo, dest *config.BackupProviderConfig, name string) *backupResult {
+	result := &backupResult{}
 
 	// build backup provider
-	backup, err := BuildBackupProvider(target.Backup)
+	backup, err := BuildBackupProvider(dest)
 	if err != nil {
-		log.Panicf("build backup provider error: %s", err.Error())
+		log.Printf("build backup provider %s error: %s", name, err.Error())
+		result.failed = len(repos)
+		return result
 	}
 
+	filter := target.DestinationFilter(dest)
+
 	// handle repos set
 	handledRepos := make(map[string]struct{})
+	// resultLock guards result and handledRepos while workers are running
+	var resultLock sync.Mutex
 
 	from := &provider.Owner{
 		Name:  target.Owner,
@@ -77,69 +404,311 @@ func (t *SyncTask) execute(target *config.GithubConfig) {
 		IsOrg: target.IsRepoOwnerOrg,
 	}
 
-	log.Printf("found %d repos in %s", len(repos), target.Owner)
-	for _, repo := range repos {
+	// state is kept per destination
+	stateKey := func(repo string) string {
+		return target.RepoOwner + "/" + name + "/" + repo
+	}
+
+	kinds := metadataKinds(target.Include)
+
+	// existing backups tell a create from an update in dry run mode
+	existing := make(map[string]struct{})
+	loadRepos := func() ([]string, error) {
+		return retry.DoValue(policy, "load "+target.RepoOwner+" repos from "+name, func() ([]string, error) {
+			return backup.LoadRepos(to)
+		})
+	}
+	if t.dryRun {
+		backupRepos, lErr := loadRepos()
+		if lErr != nil {
+			log.Printf("[dry-run] load %s repos from %s error: %s", target.RepoOwner, name, lErr.Error())
+		}
+		for _, repo := range backupRepos {
+			existing[repo] = struct{}{}
+		}
+	}
+
+	// repo info, metadata and releases are not covered by git pushes, they are exported even for unchanged repos
+	exportExtras := func(repo *provider.SourceRepo, token string) {
+		if repo.Gist {
+			return
+		}
+		fullName := target.Owner + "/" + repo.Name
+		if iErr := saveRepoInfo(backup, to, repo); iErr != nil {
+			log.Printf("save %s repo info to %s error: %s", fullName, name, iErr.Error())
+		}
+		if len(kinds) > 0 {
+			mErr := retry.Do(policy, "export "+fullName+" metadata to "+name, func() error {
+				return exportMetadata(source, backup, to, repo, token, kinds)
+			})
+			if mErr != nil {
+				log.Printf("export %s metadata to %s error: %s", fullName, name, mErr.Error())
+			}
+		}
+		if target.Include.Releases {
+			rErr := retry.Do(policy, "archive "+fullName+" releases to "+name, func() error {
+				return archiveReleases(source, backup, to, repo, token)
+			})
+			if rErr != nil {
+				log.Printf("archive %s releases to %s error: %s", fullName, name, rErr.Error())
+			}
+		}
+	}
+
+	process := func(repo *provider.SourceRepo) {
 		// render repo identity
 		identity := matcher.Identity(target.Owner, repo.Name, repo.Private, repo.Fork, repo.Archived)
+		fullName := target.Owner + "/" + repo.Name
 
 		// check allow/deny rule
-		if target.Filter != nil {
-			if !matcher.IsMatch(identity, target.Filter.AllowRule...) {
-				if matcher.IsMatch(identity, target.Filter.DenyRule...) {
-					continue
-				}
+		if isDenied(filter, identity) {
+			resultLock.Lock()
+			result.skipped++
+			resultLock.Unlock()
+			if t.dryRun {
+				log.Printf("[dry-run] skip %s", fullName)
 			}
+			return
 		}
 
-		githubToken := target.Token
-		// check specific GitHub token for this repo by regex
-		for k, v := range target.SpecificGithubToken {
-			if matcher.IsMatch(identity, k) {
-				githubToken = v
-				break
+		token := repoToken(target, identity)
+
+		// wikis are synced by the migration but not reported by the source, repos with a wiki are always migrated
+		if !(target.Include.Wiki && repo.HasWiki) && t.isUnchanged(stateKey(repo.Name), repo) {
+			resultLock.Lock()
+			result.unchanged++
+			handledRepos[repo.Name] = struct{}{}
+			resultLock.Unlock()
+			if t.dryRun {
+				log.Printf("[dry-run] skip unchanged %s in %s", fullName, name)
+				return
 			}
+			t.state.Update(stateKey(repo.Name), func(r *state.Repo) {
+				r.LastSeen = time.Now()
+				r.DeleteChecks = 0
+			})
+			log.Printf("skip unchanged %s in %s", fullName, name)
+			exportExtras(repo, token)
+			return
 		}
 
-		// migrate repo
-		delete(t.counter, repo.Name)
+		if t.dryRun {
+			action := "create"
+			resultLock.Lock()
+			if _, ok := existing[repo.Name]; ok {
+				action = "update"
+				result.updated++
+			} else {
+				result.created++
+			}
+			handledRepos[repo.Name] = struct{}{}
+			resultLock.Unlock()
+			if token != sourceToken(target) {
+				log.Printf("[dry-run] %s %s in %s using specific token", action, fullName, name)
+			} else {
+				log.Printf("[dry-run] %s %s in %s", action, fullName, name)
+			}
+			return
+		}
 
-		s, e := backup.MigrateRepo(from, to, &provider.Repo{
-			Name:        repo.Name,
-			Description: repo.Description,
-			AuthToken:   githubToken,
+		// migrate repo
+		authUsername, authToken := source.Credentials(token)
+		migrateRepo := &provider.Repo{
+			Name:         repo.Name,
+			Description:  repo.Description,
+			AuthUsername: authUsername,
+			AuthToken:    authToken,
+			CloneURL:     repo.CloneURL,
+			SSHURL:       repo.SSHURL,
+			Service:      source.Service(),
+			Gist:         repo.Gist,
+			Wiki:         target.Include.Wiki && repo.HasWiki,
+			Issues:       target.Include.Issues,
+			PullRequests: target.Include.PullRequests,
+			Labels:       target.Include.Labels,
+			Milestones:   target.Include.Milestones,
+			Releases:     target.Include.Releases,
+			LFS:          target.Include.LFS && !repo.Gist,
+		}
+		s, e := retry.DoValue(policy, "migrate "+fullName+" to "+name, func() (string, error) {
+			return backup.MigrateRepo(from, to, migrateRepo)
 		})
+		resultLock.Lock()
 		if e != nil {
-			log.Printf("migrate %s error: %s", repo.Name, e.Error())
+			result.failed++
 		} else {
-			log.Printf("migrate %s %s", repo.Name, s)
+			result.success++
 		}
 		handledRepos[repo.Name] = struct{}{}
+		resultLock.Unlock()
+		now := time.Now()
+		t.state.Update(stateKey(repo.Name), func(r *state.Repo) {
+			r.LastSeen = now
+			r.DeleteChecks = 0
+			if e != nil {
+				r.LastError = e.Error()
+				r.LastErrorAt = now
+				return
+			}
+			r.LastSuccess = now
+			r.LastError = ""
+			r.Head = repo.Head
+			r.PushedAt = repo.PushedAt
+		})
+		if e != nil {
+			log.Printf("migrate %s to %s error: %s", fullName, name, e.Error())
+			return
+		}
+		log.Printf("migrate %s to %s %s", fullName, name, s)
+		exportExtras(repo, token)
+	}
+
+	// workers are bounded by the destination limit and share the global slots with every other destination
+	workers := dest.Concurrency
+	if workers <= 0 {
+		workers = cap(t.slots)
+	}
+	jobs := make(chan *provider.SourceRepo)
+	var wg sync.WaitGroup
+	for range min(workers, max(len(repos), 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range jobs {
+				// pause before new work when the api budget of the repo token runs low, requests already running can still finish
+				if limited, ok := source.(provider.RateLimitedSource); ok && t.conf.RateLimitReserve > 0 {
+					identity := matcher.Identity(target.Owner, repo.Name, repo.Private, repo.Fork, repo.Archived)
+					url, modifiers := limited.RateLimitScope(repoToken(target, identity))
+					request.DefaultRateLimiter.WaitReserve(url, t.conf.RateLimitReserve, modifiers...)
+				}
+				t.slots <- struct{}{}
+				func() {
+					defer func() {
+						<-t.slots
+						if r := recover(); r != nil {
+							resultLock.Lock()
+							result.failed++
+							resultLock.Unlock()
+							log.Printf("migrate %s/%s to %s panic: %v", target.Owner, repo.Name, name, r)
+						}
+					}()
+					process(repo)
+				}()
+			}
+		}()
+	}
+	for _, repo := range repos {
+		jobs <- repo
 	}
+	close(jobs)
+	wg.Wait()
 
 	// delete unmatched repos if needed
-	if target.Filter.UnmatchedRepoAction == config.UnmatchedRepoActionDelete {
+	if filter.UnmatchedRepoAction == config.UnmatchedRepoActionDelete {
 		// load local repos
-		localRepos, lErr := backup.LoadRepos(to)
+		localRepos, lErr := loadRepos()
 		if lErr != nil {
-			log.Panicf("load %s repos error: %s", target.RepoOwner, lErr.Error())
+			log.Printf("load %s repos from %s error: %s", target.RepoOwner, name, lErr.Error())
+			return result
 		}
 		// delete unmatched repos
 		for _, repo := range localRepos {
 			if _, ok := handledRepos[repo]; ok {
 				continue
 			}
-			if target.Filter.PreDeleteCheckCount > 0 {
-				if t.counter[repo] < target.Filter.PreDeleteCheckCount {
-					t.counter[repo]++
+			if filter.PreDeleteCheckCount > 0 {
+				count, reached := t.checkDelete(stateKey(repo), filter.PreDeleteCheckCount)
+				if !reached {
+					result.pendingDelete++
+					if t.dryRun {
+						log.Printf("[dry-run] pending delete %s from %s, %d/%d delete checks", repo, name, count, filter.PreDeleteCheckCount)
+					}
 					continue
 				}
 			}
-			s, e := backup.DeleteRepo(target.RepoOwner, repo)
+			if t.dryRun {
+				result.deleted++
+				log.Printf("[dry-run] delete %s from %s", repo, name)
+				continue
+			}
+			s, e := func() (string, error) {
+				t.slots <- struct{}{}
+				defer func() {
+					<-t.slots
+				}()
+				return retry.DoValue(policy, "delete "+repo+" from "+name, func() (string, error) {
+					return backup.DeleteRepo(target.RepoOwner, repo)
+				})
+			}()
 			if e != nil {
-				log.Printf("delete %s error: %s", repo, e.Error())
+				result.deleteFailed++
+				log.Printf("delete %s from %s error: %s", repo, name, e.Error())
 			} else {
-				log.Printf("delete %s %s", repo, s)
+				result.deleted++
+				t.state.Delete(stateKey(repo))
+				log.Printf("delete %s from %s %s", repo, name, s)
 			}
 		}
 	}
+	return result
+}
+
+// isUnchanged reports whether repo was not pushed since its last successful migration and no full refresh is due.
+func (t *SyncTask) isUnchanged(key string, repo *provider.SourceRepo) bool {
+	if !t.conf.Incremental || (repo.Head == "" && repo.PushedAt.IsZero()) {
+		return false
+	}
+	prev := t.state.Get(key)
+	if prev.LastSuccess.IsZero() || prev.LastError != "" || time.Since(prev.LastSuccess) >= t.fullRefresh {
+		return false
+	}
+	return prev.Head == repo.Head && prev.PushedAt.Equal(repo.PushedAt)
+}
+
+// checkDelete counts one more delete check of key and reports whether the check count was already reached,
+// dry runs only report the current count without recording it.
+func (t *SyncTask) checkDelete(key string, limit int) (int, bool) {
+	checks := t.state.Get(key).DeleteChecks
+	if checks >= limit {
+		return checks, true
+	}
+	if t.dryRun {
+		return checks, false
+	}
+	t.state.Update(key, func(r *state.Repo) {
+		r.DeleteChecks++
+		checks = r.DeleteChecks
+	})
+	return checks, false
+}
+
+func isDenied(filter *config.FilterConfig, identity string) bool {
+	return !matcher.IsMatch(identity, filter.AllowRule...) && matcher.IsMatch(identity, filter.DenyRule...)
+}
+
+// repoToken returns the token repos are cloned and exported with, the specific GitHub token matching the repo identity
+// for GitHub sources, otherwise the source token.
+func repoToken(target *config.GithubConfig, identity string) string {
+	if target.Source == nil || target.Source.Type == config.SourceProviderConfigTypeGithub || target.Source.Type == "" {
+		for k, v := range target.SpecificGithubToken {
+			if matcher.IsMatch(identity, k) {
+				return v
+			}
+		}
+	}
+	return sourceToken(target)
+}
+
+// sourceToken returns the token of the source config, the target token is only used when the source has none,
+// the same way BuildSourceProvider does.
+func sourceToken(target *config.GithubConfig) string {
+	if target.Source != nil && len(target.Source.Config) > 0 {
+		c, err := config.Convert[struct {
+			Token string `json:"token"`
+		}](target.Source.Config)
+		if err == nil && c.Token != "" {
+			return c.Token
+		}
+	}
+	return target.Token
 }
@@ -0,0 +1,159 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type Error struct {
+	Args   []string
+	Stderr string
+	Err    error
+}
+
+// userinfo matches the credentials of urls, git often echoes the remote url in its errors
+var userinfo = regexp.MustCompile(`(://)[^/@\s]+@`)
+
+func (e *Error) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("git %s: %s", e.Args[0], e.Err.Error())
+	}
+	return fmt.Sprintf("git %s: %s: %s", e.Args[0], e.Err.Error(), Redact(e.Stderr))
+}
+
+// Redact removes the credentials of every url in s.
+func Redact(s string) string {
+	return userinfo.ReplaceAllString(s, "$1")
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func Output(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", &Error{Args: args, Stderr: strings.TrimSpace(stderr.String()), Err: err}
+	}
+	return string(output), nil
+}
+
+func Run(dir string, args ...string) error {
+	_, err := Output(dir, args...)
+	return err
+}
+
+func IsRepository(path string) bool {
+	output, err := Output(path, "rev-parse", "--is-inside-work-tree", "--is-bare-repository")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Fields(output) {
+		if line == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+func CloneMirror(url, path string) error {
+	return Run("", "clone", "--mirror", url, path)
+}
+
+func RemoteUpdate(path string) error {
+	return Run(path, "remote", "update", "--prune")
+}
+
+// SyncMirror clones url as a mirror into path, or updates the mirror when path already exists.
+func SyncMirror(url, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		log.Printf("cloning mirror %s", url)
+		return CloneMirror(url, path)
+	}
+	log.Printf("remote update %s", path)
+	return RemoteUpdate(path)
+}
+
+// Refs returns the object id of every ref keyed by ref name.
+func Refs(path string) (map[string]string, error) {
+	output, err := Output(path, "for-each-ref", "--format=%(objectname) %(refname)")
+	if err != nil {
+		return nil, err
+	}
+	return parseRefs(output), nil
+}
+
+// LsRemote returns the branch and tag tips of a remote, peeled tag entries are omitted.
+func LsRemote(url string) (map[string]string, error) {
+	output, err := Output("", "ls-remote", "--heads", "--tags", url)
+	if err != nil {
+		return nil, err
+	}
+	refs := parseRefs(output)
+	for ref := range refs {
+		if strings.HasSuffix(ref, "^{}") {
+			delete(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+func Fsck(path string) error {
+	return Run(path, "fsck", "--full", "--no-progress")
+}
+
+func SameRefs(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for ref, sha := range a {
+		if b[ref] != sha {
+			return false
+		}
+	}
+	return true
+}
+
+func parseRefs(output string) map[string]string {
+	refs := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		refs[fields[1]] = fields[0]
+	}
+	return refs
+}
+
+func BundleCreate(path, file string) error {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	return Run(path, "bundle", "create", file, "--all")
+}
+
+// BundleHeads returns the refs stored in a bundle, the HEAD entry is omitted to match Refs.
+func BundleHeads(file string) (map[string]string, error) {
+	output, err := Output("", "bundle", "list-heads", file)
+	if err != nil {
+		return nil, err
+	}
+	heads := parseRefs(output)
+	delete(heads, "HEAD")
+	return heads, nil
+}
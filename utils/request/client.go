@@ -3,8 +3,14 @@ package request
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -29,35 +35,97 @@ func URL(base string, query map[string]string) (string, error) {
 
 type Modifier func(client *http.Client, req *http.Request)
 
-func Request(method, url string, modifier ...Modifier) (*http.Response, error) {
-	client := DefaultHttpClient()
-	req, err := http.NewRequest(method, url, nil)
-	if err != nil {
-		return nil, err
+// StatusError is returned by GET and Send when the server responds with a non 2xx status.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
 	}
-	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	for _, m := range modifier {
-		m(client, req)
+	return fmt.Sprintf("%s %s: %d %s: %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// IsStatus reports whether err is a StatusError with one of the status codes.
+func IsStatus(err error, codes ...int) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
 	}
-	return client.Do(req)
+	return slices.Contains(codes, statusErr.StatusCode)
 }
 
-func GET[T any](url string, modifier ...Modifier) (*T, error) {
-	client := DefaultHttpClient()
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+// CheckStatus returns a StatusError with the start of the body when resp has a non 2xx status.
+func CheckStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
 	}
-	for _, m := range modifier {
-		m(client, req)
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	req := resp.Request
+	u := *req.URL
+	u.RawQuery = ""
+	u.User = nil
+	return &StatusError{
+		Method:     req.Method,
+		URL:        u.String(),
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
 	}
-	resp, err := client.Do(req)
+}
+
+func Request(method, url string, modifier ...Modifier) (*http.Response, error) {
+	return DefaultRateLimiter.do(method, url, nil, append([]Modifier{withJSONContentType}, modifier...)...)
+}
+
+func withJSONContentType(client *http.Client, req *http.Request) {
+	req.Header.Add("Content-Type", "application/json; charset=utf-8")
+}
+
+// do sends the request, waiting for the rate limit of the api and retrying once it was reset.
+func (l *RateLimiter) do(method, url string, body []byte, modifier ...Modifier) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		client := DefaultHttpClient()
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, url, reader)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range modifier {
+			m(client, req)
+		}
+		l.Wait(req)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		wait, limited := l.Update(req, resp)
+		if !limited || attempt >= maxRateLimitAttempts {
+			return resp, nil
+		}
+		_ = resp.Body.Close()
+		log.Printf("rate limited by %s, retry in %s", req.URL.Host, wait)
+		time.Sleep(wait)
+	}
+}
+
+func GET[T any](url string, modifier ...Modifier) (*T, error) {
+	resp, err := DefaultRateLimiter.do(http.MethodGet, url, nil, modifier...)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
+	if err = CheckStatus(resp); err != nil {
+		return nil, err
+	}
 	var result T
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
@@ -67,28 +135,34 @@ func GET[T any](url string, modifier ...Modifier) (*T, error) {
 }
 
 func POST[T any](url string, data any, modifier ...Modifier) (*T, error) {
-	client := DefaultHttpClient()
+	return Send[T]("POST", url, data, modifier...)
+}
+
+func PUT[T any](url string, data any, modifier ...Modifier) (*T, error) {
+	return Send[T]("PUT", url, data, modifier...)
+}
+
+func Send[T any](method, url string, data any, modifier ...Modifier) (*T, error) {
 	body, err := json.Marshal(data)
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	for _, m := range modifier {
-		m(client, req)
-	}
-	resp, err := client.Do(req)
+	resp, err := DefaultRateLimiter.do(method, url, body, append([]Modifier{withJSONContentType}, modifier...)...)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
+	if err = CheckStatus(resp); err != nil {
+		return nil, err
+	}
 	var result T
 	err = json.NewDecoder(resp.Body).Decode(&result)
+	// only successful responses without content like 204 No Content may have no body to decode
+	if errors.Is(err, io.EOF) && (resp.StatusCode == http.StatusNoContent || resp.ContentLength == 0) {
+		return &result, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -100,3 +174,21 @@ func WithAuthorization(token string, prefix string) Modifier {
 		req.Header.Add("Authorization", prefix+" "+token)
 	}
 }
+
+func WithBasicAuth(username, password string) Modifier {
+	return func(client *http.Client, req *http.Request) {
+		req.SetBasicAuth(username, password)
+	}
+}
+
+func WithHeader(key, value string) Modifier {
+	return func(client *http.Client, req *http.Request) {
+		req.Header.Set(key, value)
+	}
+}
+
+func WithTimeout(timeout time.Duration) Modifier {
+	return func(client *http.Client, req *http.Request) {
+		client.Timeout = timeout
+	}
+}
@@ -0,0 +1,23 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSend_EmptyBody(t *testing.T) {
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	if _, err := PUT[struct{}](server.URL, map[string]string{}); err != nil {
+		t.Fatalf("expected an empty 204 response to succeed, got %v", err)
+	}
+	status = http.StatusInternalServerError
+	if _, err := POST[struct{}](server.URL, map[string]string{}); !IsStatus(err, http.StatusInternalServerError) {
+		t.Fatalf("expected an empty error response to fail, got %v", err)
+	}
+}
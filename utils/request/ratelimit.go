@@ -0,0 +1,161 @@
+package request
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRateLimitAttempts bounds how often a single request waits for a rate limit before its response is returned as is.
+const maxRateLimitAttempts = 3
+
+// RateLimit is the last known budget of one api resource for one credential.
+type RateLimit struct {
+	Host      string
+	Resource  string
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	auth      string
+}
+
+// RateLimiter tracks the X-RateLimit-* headers of every response and holds back requests once a budget is exhausted.
+type RateLimiter struct {
+	lock   sync.Mutex
+	limits map[string]*RateLimit
+}
+
+var DefaultRateLimiter = NewRateLimiter()
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		limits: make(map[string]*RateLimit),
+	}
+}
+
+// resource follows the GitHub split of budgets, GraphQL queries have their own budget next to the REST api.
+func resource(req *http.Request) string {
+	if strings.HasSuffix(req.URL.Path, "/graphql") {
+		return "graphql"
+	}
+	return "core"
+}
+
+// auth identifies the credentials of a request, they are hashed so they are never kept in memory in plain text.
+func auth(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Header.Get("Authorization")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// key identifies a budget.
+func key(req *http.Request) string {
+	return req.URL.Host + "/" + resource(req) + "/" + auth(req)
+}
+
+// Wait blocks until the budget of the request is reset when it is known to be exhausted.
+func (l *RateLimiter) Wait(req *http.Request) {
+	l.lock.Lock()
+	limit, ok := l.limits[key(req)]
+	var wait time.Duration
+	if ok && limit.Remaining <= 0 {
+		wait = time.Until(limit.Reset)
+	}
+	l.lock.Unlock()
+	if wait > 0 {
+		log.Printf("rate limit of %s %s exhausted, waiting %s until reset", req.URL.Host, resource(req), wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+}
+
+// WaitReserve blocks while a budget of the api at url for the credentials set by modifier is below reserve and not reset yet,
+// it lets the scheduler pause before starting new work instead of running the budget dry.
+func (l *RateLimiter) WaitReserve(url string, reserve int, modifier ...Modifier) {
+	if reserve <= 0 {
+		return
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	for _, m := range modifier {
+		m(DefaultHttpClient(), req)
+	}
+	host, credentials := req.URL.Host, auth(req)
+	for {
+		var low *RateLimit
+		for _, limit := range l.Budgets() {
+			if limit.Host != host || limit.auth != credentials {
+				continue
+			}
+			if limit.Remaining < reserve && time.Now().Before(limit.Reset) && (low == nil || limit.Reset.After(low.Reset)) {
+				low = limit
+			}
+		}
+		if low == nil {
+			return
+		}
+		wait := time.Until(low.Reset)
+		log.Printf("rate limit of %s %s below reserve %d/%d, waiting %s until reset", low.Host, low.Resource, low.Remaining, reserve, wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+}
+
+// Update records the budget reported by resp and reports how long to wait when the request was rate limited.
+func (l *RateLimiter) Update(req *http.Request, resp *http.Response) (time.Duration, bool) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	hasBudget := err == nil
+	var reset time.Time
+	if hasBudget {
+		limit, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+		if epoch, rErr := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); rErr == nil {
+			reset = time.Unix(epoch, 0)
+		}
+		l.lock.Lock()
+		l.limits[key(req)] = &RateLimit{
+			Host:      req.URL.Host,
+			Resource:  resource(req),
+			Limit:     limit,
+			Remaining: remaining,
+			Reset:     reset,
+			auth:      auth(req),
+		}
+		l.lock.Unlock()
+	}
+
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	// secondary rate limits tell how long to back off
+	if seconds, sErr := strconv.Atoi(resp.Header.Get("Retry-After")); sErr == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	// the primary rate limit is exhausted, wait for the reset with a little slack for clock skew
+	if hasBudget && remaining == 0 && !reset.IsZero() {
+		return max(time.Until(reset), 0) + time.Second, true
+	}
+	return 0, false
+}
+
+// Budgets returns a copy of every known budget ordered by host and resource.
+func (l *RateLimiter) Budgets() []*RateLimit {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	budgets := make([]*RateLimit, 0, len(l.limits))
+	for _, limit := range l.limits {
+		budget := *limit
+		budgets = append(budgets, &budget)
+	}
+	sort.Slice(budgets, func(i, j int) bool {
+		if budgets[i].Host != budgets[j].Host {
+			return budgets[i].Host < budgets[j].Host
+		}
+		return budgets[i].Resource < budgets[j].Resource
+	})
+	return budgets
+}
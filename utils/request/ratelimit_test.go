@@ -0,0 +1,92 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGET_RetryAfter(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message": "You have exceeded a secondary rate limit"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"name": "repo"}`))
+	}))
+	defer server.Close()
+
+	limiter := NewRateLimiter()
+	resp, err := limiter.do(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls != 2 {
+		t.Fatalf("expected a retried request, got %d calls and status %d", calls, resp.StatusCode)
+	}
+}
+
+func TestRateLimiter_WaitReserve(t *testing.T) {
+	limiter := NewRateLimiter()
+	req := httptest.NewRequest(http.MethodGet, "https://api.github.com/repos/a/b", nil)
+	req.Header.Set("Authorization", "bearer exhausted")
+	limiter.Update(req, &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"X-Ratelimit-Limit":     []string{"5000"},
+			"X-Ratelimit-Remaining": []string{"10"},
+			"X-Ratelimit-Reset":     []string{strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)},
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		limiter.WaitReserve("https://api.github.com", 100, WithAuthorization("other", "bearer"))
+		limiter.WaitReserve("https://gitlab.com", 100, WithAuthorization("exhausted", "bearer"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("an exhausted budget should not block other tokens and hosts")
+	}
+}
+
+func TestRateLimiter_Update(t *testing.T) {
+	limiter := NewRateLimiter()
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	req := httptest.NewRequest(http.MethodPost, "https://api.github.com/graphql", nil)
+	req.Header.Set("Authorization", "bearer token")
+	resp := &http.Response{
+		StatusCode: http.StatusForbidden,
+		Header: http.Header{
+			"X-Ratelimit-Limit":     []string{"5000"},
+			"X-Ratelimit-Remaining": []string{"0"},
+			"X-Ratelimit-Reset":     []string{strconv.FormatInt(reset.Unix(), 10)},
+		},
+	}
+	wait, limited := limiter.Update(req, resp)
+	if !limited || wait < 59*time.Minute {
+		t.Fatalf("expected to wait for the reset, got %s %v", wait, limited)
+	}
+	budgets := limiter.Budgets()
+	if len(budgets) != 1 {
+		t.Fatalf("expected 1 budget, got %d", len(budgets))
+	}
+	budget := budgets[0]
+	if budget.Host != "api.github.com" || budget.Resource != "graphql" || budget.Limit != 5000 || budget.Remaining != 0 || !budget.Reset.Equal(reset) {
+		t.Fatalf("unexpected budget %+v", budget)
+	}
+
+	resp.StatusCode = http.StatusNotFound
+	if _, limited = limiter.Update(req, resp); limited {
+		t.Fatal("expected a not found response not to be rate limited")
+	}
+}
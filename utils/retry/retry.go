@@ -0,0 +1,109 @@
+package retry
+
+import (
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net/url"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/TBXark/github-backup/utils/git"
+	"github.com/TBXark/github-backup/utils/request"
+)
+
+var DefaultStatusCodes = []int{408, 429, 500, 502, 503, 504}
+
+// DefaultGitErrorPatterns match the stderr of git commands that failed because of the network or an overloaded remote.
+var DefaultGitErrorPatterns = []string{
+	`(?i)could not resolve host`,
+	`(?i)connection (timed out|reset|refused)`,
+	`(?i)operation timed out`,
+	`(?i)the remote end hung up unexpectedly`,
+	`(?i)early EOF`,
+	`(?i)RPC failed`,
+	`(?i)TLS connection was non-properly terminated`,
+	`(?i)returned error: (408|429|5\d\d)`,
+}
+
+type Policy struct {
+	MaxAttempts      int
+	InitialBackoff   time.Duration
+	MaxBackoff       time.Duration
+	Jitter           float64
+	StatusCodes      []int
+	GitErrorPatterns []*regexp.Regexp
+}
+
+func DefaultPolicy() *Policy {
+	patterns := make([]*regexp.Regexp, 0, len(DefaultGitErrorPatterns))
+	for _, p := range DefaultGitErrorPatterns {
+		patterns = append(patterns, regexp.MustCompile(p))
+	}
+	return &Policy{
+		MaxAttempts:      3,
+		InitialBackoff:   time.Second,
+		MaxBackoff:       30 * time.Second,
+		Jitter:           0.2,
+		StatusCodes:      DefaultStatusCodes,
+		GitErrorPatterns: patterns,
+	}
+}
+
+// Retryable reports whether err is a transient failure worth another attempt.
+func (p *Policy) Retryable(err error) bool {
+	var statusErr *request.StatusError
+	if errors.As(err, &statusErr) {
+		return slices.Contains(p.StatusCodes, statusErr.StatusCode)
+	}
+	var gitErr *git.Error
+	if errors.As(err, &gitErr) {
+		for _, pattern := range p.GitErrorPatterns {
+			if pattern.MatchString(gitErr.Stderr) {
+				return true
+			}
+		}
+		return false
+	}
+	// the request never got a response, e.g. dns, connection or timeout errors
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// Backoff returns the wait before the next attempt, it doubles with every attempt up to MaxBackoff
+// and is spread by Jitter in both directions.
+func (p *Policy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		backoff += time.Duration(float64(backoff) * p.Jitter * (rand.Float64()*2 - 1))
+	}
+	return max(backoff, 0)
+}
+
+// Do runs fn until it succeeds, fails with an error that is not retryable or runs out of attempts,
+// a nil policy runs fn once.
+func Do(p *Policy, name string, fn func() error) error {
+	_, err := DoValue(p, name, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+func DoValue[T any](p *Policy, name string, fn func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		value, err := fn()
+		if err == nil || p == nil || attempt >= p.MaxAttempts || !p.Retryable(err) {
+			return value, err
+		}
+		wait := p.Backoff(attempt)
+		log.Printf("%s attempt %d/%d failed, retry in %s: %s", name, attempt, p.MaxAttempts, wait.Round(time.Millisecond), err.Error())
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,81 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/TBXark/github-backup/utils/git"
+	"github.com/TBXark/github-backup/utils/request"
+)
+
+func TestPolicy_Retryable(t *testing.T) {
+	p := DefaultPolicy()
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&request.StatusError{StatusCode: 502}, true},
+		{fmt.Errorf("load repos: %w", &request.StatusError{StatusCode: 429}), true},
+		{&request.StatusError{StatusCode: 404}, false},
+		{&git.Error{Args: []string{"fetch"}, Stderr: "fatal: unable to access 'https://github.com/a/b.git/': Could not resolve host: github.com"}, true},
+		{&git.Error{Args: []string{"fetch"}, Stderr: "error: RPC failed; HTTP 502 curl 22 The requested URL returned error: 502"}, true},
+		{&git.Error{Args: []string{"clone"}, Stderr: "fatal: repository 'https://github.com/a/b.git/' not found"}, false},
+		{errors.New("invalid config"), false},
+	}
+	for _, c := range cases {
+		if got := p.Retryable(c.err); got != c.want {
+			t.Errorf("Retryable(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	p := &Policy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := p.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.Backoff(1); got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Fatalf("Backoff(1) with jitter = %s, out of range", got)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := &Policy{MaxAttempts: 3, StatusCodes: []int{503}}
+	calls := 0
+	err := Do(p, "test", func() error {
+		calls++
+		if calls < 3 {
+			return &request.StatusError{StatusCode: 503}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success after 3 calls, got %d calls and %v", calls, err)
+	}
+
+	calls = 0
+	err = Do(p, "test", func() error {
+		calls++
+		return &request.StatusError{StatusCode: 401}
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected a single call for non retryable errors, got %d calls and %v", calls, err)
+	}
+
+	calls = 0
+	err = Do(p, "test", func() error {
+		calls++
+		return &request.StatusError{StatusCode: 503}
+	})
+	if err == nil || calls != 3 {
+		t.Fatalf("expected 3 calls before giving up, got %d calls and %v", calls, err)
+	}
+}
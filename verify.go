@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/TBXark/github-backup/config"
+	"github.com/TBXark/github-backup/provider/provider"
+	"github.com/TBXark/github-backup/utils/git"
+	"github.com/TBXark/github-backup/utils/matcher"
+)
+
+type VerifyStatus string
+
+const (
+	VerifyStatusOK          VerifyStatus = "ok"
+	VerifyStatusMissing     VerifyStatus = "missing"
+	VerifyStatusStale       VerifyStatus = "stale"
+	VerifyStatusCorrupt     VerifyStatus = "corrupt"
+	VerifyStatusExtra       VerifyStatus = "extra"
+	VerifyStatusUnsupported VerifyStatus = "unsupported"
+	VerifyStatusError       VerifyStatus = "error"
+)
+
+type VerifyResult struct {
+	Owner       string       `json:"owner"`
+	Destination string       `json:"destination"`
+	Repo        string       `json:"repo"`
+	Status      VerifyStatus `json:"status"`
+	Detail      string       `json:"detail,omitempty"`
+}
+
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	conf := flags.String("config", "config.json", "config file")
+	owner := flags.String("owner", "", "owner of the target to verify, defaults to all targets")
+	backup := flags.String("backup", "", "name of the backup destination to verify, defaults to all destinations")
+	format := flags.String("format", "table", "output format, table or json")
+	_ = flags.Parse(args)
+
+	if *format != "table" && *format != "json" {
+		log.Fatalf("unknown output format: %s", *format)
+	}
+	data, err := LoadConfig(*conf)
+	if err != nil {
+		log.Fatalf("load config error: %s", err.Error())
+	}
+
+	results := make([]*VerifyResult, 0)
+	for _, target := range data.Targets {
+		if *owner != "" && target.Owner != *owner {
+			continue
+		}
+		target.MergeDefault(data.DefaultConf)
+		names, nErr := target.DestinationNames()
+		if nErr != nil {
+			log.Fatalf("%s", nErr.Error())
+		}
+		for i, dest := range target.Destinations() {
+			name := names[i]
+			if *backup != "" && name != *backup {
+				continue
+			}
+			results = append(results, verify(target, dest, name)...)
+		}
+	}
+
+	if *format == "json" {
+		err = json.NewEncoder(os.Stdout).Encode(results)
+	} else {
+		err = printVerifyTable(os.Stdout, results)
+	}
+	if err != nil {
+		log.Fatalf("print results error: %s", err.Error())
+	}
+	for _, result := range results {
+		if result.Status != VerifyStatusOK {
+			os.Exit(1)
+		}
+	}
+}
+
+func printVerifyTable(out io.Writer, results []*VerifyResult) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "OWNER\tDESTINATION\tREPO\tSTATUS\tDETAIL")
+	for _, r := range results {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Owner, r.Destination, r.Repo, r.Status, strings.ReplaceAll(r.Detail, "\n", "; "))
+	}
+	return w.Flush()
+}
+
+func verify(target *config.GithubConfig, dest *config.BackupProviderConfig, name string) []*VerifyResult {
+	newResult := func(repo string, status VerifyStatus, detail string) *VerifyResult {
+		return &VerifyResult{
+			Owner:       target.Owner,
+			Destination: name,
+			Repo:        repo,
+			Status:      status,
+			Detail:      git.Redact(detail),
+		}
+	}
+
+	source, err := BuildSourceProvider(target.Source, target.Token)
+	if err != nil {
+		return []*VerifyResult{newResult("", VerifyStatusUnsupported, err.Error())}
+	}
+	backup, err := BuildBackupProvider(dest)
+	if err != nil {
+		return []*VerifyResult{newResult("", VerifyStatusUnsupported, err.Error())}
+	}
+	exporter, ok := backup.(provider.Exporter)
+	if !ok {
+		return []*VerifyResult{newResult("", VerifyStatusUnsupported, fmt.Sprintf("backup provider %s can not be verified", dest.Type))}
+	}
+
+	from := &provider.Owner{
+		Name:  target.Owner,
+		IsOrg: target.IsOwnerOrg,
+	}
+	to := &provider.Owner{
+		Name:  target.RepoOwner,
+		IsOrg: target.IsRepoOwnerOrg,
+	}
+	repos, err := source.ListRepos(from)
+	if err != nil {
+		return []*VerifyResult{newResult("", VerifyStatusError, err.Error())}
+	}
+	backupRepos, err := backup.LoadRepos(to)
+	if err != nil {
+		return []*VerifyResult{newResult("", VerifyStatusError, err.Error())}
+	}
+	existing := make(map[string]struct{}, len(backupRepos))
+	for _, repo := range backupRepos {
+		existing[repo] = struct{}{}
+	}
+
+	filter := target.DestinationFilter(dest)
+
+	results := make([]*VerifyResult, 0, len(repos))
+	expected := make(map[string]struct{}, len(repos))
+	for _, repo := range repos {
+		identity := matcher.Identity(target.Owner, repo.Name, repo.Private, repo.Fork, repo.Archived)
+		if isDenied(filter, identity) {
+			continue
+		}
+		expected[repo.Name] = struct{}{}
+		if _, ok := existing[repo.Name]; !ok {
+			results = append(results, newResult(repo.Name, VerifyStatusMissing, ""))
+			continue
+		}
+		username, password := source.Credentials(repoToken(target, identity))
+		status, detail := verifyRepo(exporter, to, repo, username, password)
+		results = append(results, newResult(repo.Name, status, detail))
+	}
+	for _, repo := range backupRepos {
+		if _, ok := expected[repo]; !ok {
+			results = append(results, newResult(repo, VerifyStatusExtra, ""))
+		}
+	}
+	return results
+}
+
+func verifyRepo(exporter provider.Exporter, owner *provider.Owner, repo *provider.SourceRepo, username, password string) (VerifyStatus, string) {
+	location, err := exporter.ExportRepo(owner, repo.Name)
+	if err != nil {
+		return VerifyStatusCorrupt, err.Error()
+	}
+
+	// local repositories are checked in place, everything else is cloned first
+	path := location
+	if !git.IsRepository(location) {
+		dir, tErr := os.MkdirTemp("", "github-backup-verify-")
+		if tErr != nil {
+			return VerifyStatusCorrupt, tErr.Error()
+		}
+		defer func() {
+			_ = os.RemoveAll(dir)
+		}()
+		path = filepath.Join(dir, repo.Name+".git")
+		if cErr := git.CloneMirror(location, path); cErr != nil {
+			return VerifyStatusCorrupt, cErr.Error()
+		}
+	}
+	if err = git.Fsck(path); err != nil {
+		return VerifyStatusCorrupt, err.Error()
+	}
+	refs, err := git.Refs(path)
+	if err != nil {
+		return VerifyStatusCorrupt, err.Error()
+	}
+
+	prefix := branchPrefix(refs)
+	backupRefs := make(map[string]string, len(refs))
+	for ref, sha := range refs {
+		switch {
+		case strings.HasPrefix(ref, "refs/tags/"):
+			backupRefs[ref] = sha
+		case strings.HasPrefix(ref, prefix) && ref != prefix+"HEAD":
+			backupRefs["refs/heads/"+strings.TrimPrefix(ref, prefix)] = sha
+		}
+	}
+
+	sourceRefs, err := git.LsRemote(provider.AuthURL(repo.CloneURL, username, password))
+	if err != nil {
+		return VerifyStatusError, fmt.Sprintf("list source refs error: %s", err.Error())
+	}
+	stale := make([]string, 0)
+	for ref, sha := range sourceRefs {
+		if backupRefs[ref] != sha {
+			stale = append(stale, ref)
+		}
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		detail := fmt.Sprintf("%d refs behind source: %s", len(stale), strings.Join(stale[:min(len(stale), 3)], ", "))
+		if len(stale) > 3 {
+			detail += ", ..."
+		}
+		return VerifyStatusStale, detail
+	}
+	return VerifyStatusOK, ""
+}
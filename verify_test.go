@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/TBXark/github-backup/config"
+	"github.com/TBXark/github-backup/provider/github"
+	"github.com/TBXark/github-backup/provider/local"
+)
+
+func TestVerify_ListFailures(t *testing.T) {
+	failSource := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failSource {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"repositories": {"repositories": {"pageInfo": {"hasNextPage": false}, "nodes": [{"name": "api", "owner": {"login": "acme"}}]}}}}`))
+	}))
+	defer server.Close()
+
+	target := &config.GithubConfig{
+		Owner: "acme",
+		Source: &config.SourceProviderConfig{
+			Type:   config.SourceProviderConfigTypeGithub,
+			Config: config.ToRaw(github.Config{APIURL: server.URL, Token: "secret"}),
+		},
+		// the owner directory of the backup does not exist, so loading the backup repos fails
+		Backup: &config.BackupProviderConfig{
+			Type:   config.BackupProviderConfigTypeLocal,
+			Config: config.ToRaw(local.Config{Root: filepath.Join(t.TempDir(), "missing")}),
+		},
+	}
+	target.MergeDefault(nil)
+
+	for _, fail := range []bool{false, true} {
+		failSource = fail
+		results := verify(target, target.Backup, "local#0")
+		if len(results) != 1 {
+			t.Errorf("expect a single error result when the source fails is %t, got %d results", fail, len(results))
+			continue
+		}
+		if results[0].Status != VerifyStatusError {
+			t.Errorf("expect status error when the source fails is %t, got %s: %s", fail, results[0].Status, results[0].Detail)
+		}
+	}
+}